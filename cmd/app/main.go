@@ -3,17 +3,27 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/defskela/SocialNetwork/internal/config"
 	"github.com/defskela/SocialNetwork/internal/delivery/http"
+	v1 "github.com/defskela/SocialNetwork/internal/delivery/http/v1"
 	"github.com/defskela/SocialNetwork/internal/repository"
 	"github.com/defskela/SocialNetwork/internal/repository/postgres"
 	"github.com/defskela/SocialNetwork/internal/service"
 	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+	"github.com/defskela/SocialNetwork/pkg/hasher"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
 	"github.com/defskela/SocialNetwork/pkg/migrator"
+	"github.com/defskela/SocialNetwork/pkg/oidc"
+
+	"github.com/google/uuid"
 )
 
 // @title Social Network API
@@ -50,12 +60,148 @@ func run() error {
 
 	userRepo := postgres.NewUserRepository(pgClient)
 	postRepo := postgres.NewPostRepository(pgClient)
-	repos := repository.NewRepository(userRepo, postRepo)
-	services, err := service.NewService(repos, cfg.JWT.PrivateKeyPath, cfg.JWT.PublicKeyPath)
+	refreshTokenRepo := postgres.NewRefreshTokenRepository(pgClient)
+	sessionRepo := postgres.NewSessionRepository(pgClient)
+	passwordResetRepo := postgres.NewPasswordResetRepository(pgClient)
+	mfaRepo := postgres.NewMFARepository(pgClient)
+	loginThrottleRepo := postgres.NewLoginThrottleRepository(pgClient)
+	accessTokenRepo := postgres.NewPersonalAccessTokenRepository(pgClient)
+	roleRepo := postgres.NewRoleRepository(pgClient)
+	oidcRepo := postgres.NewOIDCRepository(pgClient)
+	magicLinkRepo := postgres.NewMagicLinkRepository(pgClient)
+	followRepo := postgres.NewFollowRepository(pgClient)
+	blockRepo := postgres.NewBlockRepository(pgClient)
+	timelineRepo := postgres.NewTimelineRepository(pgClient)
+	likeRepo := postgres.NewLikeRepository(pgClient)
+	reactionRepo := postgres.NewReactionRepository(pgClient)
+	repos := repository.NewRepository(
+		userRepo,
+		postRepo,
+		refreshTokenRepo,
+		sessionRepo,
+		passwordResetRepo,
+		mfaRepo,
+		loginThrottleRepo,
+		accessTokenRepo,
+		roleRepo,
+		oidcRepo,
+		magicLinkRepo,
+		followRepo,
+		blockRepo,
+		timelineRepo,
+		likeRepo,
+		reactionRepo,
+	)
+
+	mail, err := newMailer(&cfg.Mail)
+	if err != nil {
+		return fmt.Errorf("failed to create mailer: %w", err)
+	}
+
+	admins, err := parseUserIDs(cfg.Auth.Admins)
+	if err != nil {
+		return fmt.Errorf("invalid admins: %w", err)
+	}
+
+	trustedProxies, err := parsePrefixes(cfg.HTTPServer.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	providers, err := oidcProviders(cfg.OIDC.Providers, cfg.JWT.Leeway)
+	if err != nil {
+		return fmt.Errorf("invalid oidc providers: %w", err)
+	}
+
+	var keys *keyring.Ring
+	if cfg.JWT.KeysDir != "" {
+		keys, err = keyring.Load(cfg.JWT.KeysDir, cfg.JWT.Algorithm)
+		if err != nil {
+			return fmt.Errorf("failed to load signing keys: %w", err)
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go reloadKeys(keys, hup)
+	}
+
+	passwordHasher, err := hasher.New(cfg.Password.Algorithm, hasher.Argon2idParams{
+		Memory:      cfg.Password.Argon2Memory,
+		Iterations:  cfg.Password.Argon2Iterations,
+		Parallelism: cfg.Password.Argon2Parallelism,
+	}, cfg.Password.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("failed to configure password hashing: %w", err)
+	}
+
+	var timeline *service.TimelineWorker
+	if cfg.Timeline.FanOut {
+		timeline = service.NewTimelineWorker(repos, service.TimelineConfig{
+			CelebrityFollowers: cfg.Timeline.CelebrityFollowers,
+			BatchSize:          cfg.Timeline.BatchSize,
+			PollInterval:       cfg.Timeline.PollInterval,
+		})
+		go timeline.Run(ctx)
+	}
+
+	services, err := service.NewService(repos, mail, service.Config{
+		Auth: service.AuthConfig{
+			Keys:                 keys,
+			Algorithm:            cfg.JWT.Algorithm,
+			PrivateKeyPath:       cfg.JWT.PrivateKeyPath,
+			PublicKeyPath:        cfg.JWT.PublicKeyPath,
+			AccessTokenTTL:       cfg.JWT.AccessTokenTTL,
+			Issuer:               cfg.JWT.Issuer,
+			Audience:             cfg.JWT.Audience,
+			Leeway:               cfg.JWT.Leeway,
+			RefreshTokenTTL:      cfg.JWT.RefreshTokenTTL,
+			PublicURL:            cfg.Auth.PublicURL,
+			EmailVerificationTTL: cfg.Auth.EmailVerificationTTL,
+			PasswordResetTTL:     cfg.Auth.PasswordResetTTL,
+			MFAChallengeTTL:      cfg.Auth.MFAChallengeTTL,
+			TOTPIssuer:           cfg.Auth.TOTPIssuer,
+			RevocationCacheTTL:   cfg.JWT.RevocationCacheTTL,
+			PasswordPolicy: service.PasswordPolicyConfig{
+				MinLength:        cfg.Password.MinLength,
+				BreachedListPath: cfg.Password.BreachedListPath,
+			},
+			PasswordHasher: passwordHasher,
+			LoginThrottle: service.LoginThrottleConfig{
+				FreeAttempts:     cfg.LoginThrottle.FreeAttempts,
+				IPFreeAttempts:   cfg.LoginThrottle.IPFreeAttempts,
+				BaseDelay:        cfg.LoginThrottle.BaseDelay,
+				MaxDelay:         cfg.LoginThrottle.MaxDelay,
+				LockoutThreshold: cfg.LoginThrottle.LockoutThreshold,
+				LockoutDuration:  cfg.LoginThrottle.LockoutDuration,
+				Window:           cfg.LoginThrottle.Window,
+			},
+			OIDCProviders: providers,
+			OIDCStateTTL:  cfg.OIDC.StateTTL,
+			MagicLink: service.MagicLinkConfig{
+				TTL:         cfg.MagicLink.TTL,
+				MaxRequests: cfg.MagicLink.MaxRequests,
+				Window:      cfg.MagicLink.Window,
+			},
+		},
+		Post: service.PostConfig{
+			RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+			Timeline:             timeline,
+			Reactions:            cfg.Reactions.Allowed,
+		},
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create services: %w", err)
 	}
-	handlers := http.NewHandler(services)
+
+	grantAdmins(ctx, repos.Role, admins)
+
+	handlers := http.NewHandler(services, http.Config{
+		V1: v1.Config{
+			PublicReadRate:  cfg.RateLimit.PublicReadRate,
+			PublicReadBurst: cfg.RateLimit.PublicReadBurst,
+		},
+		TrustedProxies: trustedProxies,
+	})
 
 	srv := http.NewServer(cfg, handlers.Init())
 
@@ -79,3 +225,107 @@ func run() error {
 
 	return nil
 }
+
+// reloadKeys re-reads the key ring on every signal, which is how keys are rotated without a restart.
+func reloadKeys(keys *keyring.Ring, signals <-chan os.Signal) {
+	for range signals {
+		if err := keys.Reload(); err != nil {
+			fmt.Printf("failed to reload signing keys: %s\n", err)
+			continue
+		}
+		fmt.Printf("signing keys reloaded, active key %s\n", keys.Active().ID)
+	}
+}
+
+// grantAdmins gives the admin role to the users listed in the config, so that a deployment always has an admin
+// who can hand out further roles.
+func grantAdmins(ctx context.Context, roles repository.RoleRepository, admins []uuid.UUID) {
+	for _, id := range admins {
+		if err := roles.Assign(ctx, id, service.RoleAdmin); err != nil {
+			fmt.Printf("failed to grant admin role to %s: %s\n", id, err)
+		}
+	}
+}
+
+func parseUserIDs(values []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, v := range values {
+		id, err := uuid.Parse(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", v, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parsePrefixes reads CIDR ranges, taking a bare address as a range of its own.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", v, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", v, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// oidcProviders turns the configured providers into client configs by name.
+func oidcProviders(providers []config.OIDCProvider, leeway time.Duration) (map[string]oidc.Config, error) {
+	configs := make(map[string]oidc.Config, len(providers))
+	for _, p := range providers {
+		if p.Name == "" || p.IssuerURL == "" || p.ClientID == "" || p.RedirectURL == "" {
+			return nil, fmt.Errorf("provider %q needs a name, issuer_url, client_id and redirect_url", p.Name)
+		}
+
+		if _, ok := configs[p.Name]; ok {
+			return nil, fmt.Errorf("provider %q is configured twice", p.Name)
+		}
+
+		secret := p.ClientSecret
+		if p.ClientSecretEnv != "" {
+			secret = os.Getenv(p.ClientSecretEnv)
+		}
+
+		configs[p.Name] = oidc.Config{
+			Issuer:       p.IssuerURL,
+			ClientID:     p.ClientID,
+			ClientSecret: secret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+			Leeway:       leeway,
+		}
+	}
+	return configs, nil
+}
+
+func newMailer(cfg *config.Mail) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}), nil
+	case "file":
+		return mailer.NewFile(cfg.Dir, cfg.From)
+	case "memory":
+		return mailer.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
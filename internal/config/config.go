@@ -13,12 +13,25 @@ type Config struct {
 	HTTPServer `yaml:"http_server"`
 	Postgres   `yaml:"postgres"`
 	JWT        `yaml:"jwt"`
+	Auth       `yaml:"auth"`
+	Mail       `yaml:"mail"`
+	Password   `yaml:"password"`
+
+	LoginThrottle `yaml:"login_throttle"`
+	OIDC          `yaml:"oidc"`
+	MagicLink     `yaml:"magic_link"`
+	RateLimit     `yaml:"rate_limit"`
+	Timeline      `yaml:"timeline"`
+	Reactions     `yaml:"reactions"`
 }
 
 type HTTPServer struct {
 	Address     string        `yaml:"address" env:"HTTP_SERVER_ADDRESS" env-default:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse proxies in front of the server.
+	// Client addresses in forwarding headers are only believed when they come from one of them.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"HTTP_SERVER_TRUSTED_PROXIES" env-separator:","`
 }
 
 type Postgres struct {
@@ -31,8 +44,112 @@ type Postgres struct {
 }
 
 type JWT struct {
-	PrivateKeyPath string `yaml:"private_key_path" env:"JWT_PRIVATE_KEY_PATH" env-required:"true"`
-	PublicKeyPath  string `yaml:"public_key_path" env:"JWT_PUBLIC_KEY_PATH" env-required:"true"`
+	// KeysDir holds a key ring for rotation, see keyring.Load. When empty, the single
+	// key pair at PrivateKeyPath and PublicKeyPath is used.
+	KeysDir string `yaml:"keys_dir" env:"JWT_KEYS_DIR"`
+	// Algorithm is RS256, ES256 or EdDSA and has to match the type of the signing key.
+	Algorithm          string        `yaml:"algorithm" env:"JWT_ALGORITHM" env-default:"RS256"`
+	PrivateKeyPath     string        `yaml:"private_key_path" env:"JWT_PRIVATE_KEY_PATH"`
+	PublicKeyPath      string        `yaml:"public_key_path" env:"JWT_PUBLIC_KEY_PATH"`
+	Issuer             string        `yaml:"issuer" env:"JWT_ISSUER" env-default:"http://localhost:8080"`
+	Audience           string        `yaml:"audience" env:"JWT_AUDIENCE" env-default:"social-network-api"`
+	Leeway             time.Duration `yaml:"leeway" env:"JWT_LEEWAY" env-default:"30s"`
+	AccessTokenTTL     time.Duration `yaml:"access_token_ttl" env:"JWT_ACCESS_TOKEN_TTL" env-default:"12h"`
+	RefreshTokenTTL    time.Duration `yaml:"refresh_token_ttl" env:"JWT_REFRESH_TOKEN_TTL" env-default:"720h"`
+	RevocationCacheTTL time.Duration `yaml:"revocation_cache_ttl" env:"JWT_REVOCATION_CACHE_TTL" env-default:"30s"`
+}
+
+type Auth struct {
+	PublicURL            string        `yaml:"public_url" env:"AUTH_PUBLIC_URL" env-default:"http://localhost:8080"`
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl" env:"AUTH_EMAIL_VERIFICATION_TTL" env-default:"24h"`
+	PasswordResetTTL     time.Duration `yaml:"password_reset_ttl" env:"AUTH_PASSWORD_RESET_TTL" env-default:"1h"`
+	RequireVerifiedEmail bool          `yaml:"require_verified_email" env:"AUTH_REQUIRE_VERIFIED_EMAIL" env-default:"false"`
+	MFAChallengeTTL      time.Duration `yaml:"mfa_challenge_ttl" env:"AUTH_MFA_CHALLENGE_TTL" env-default:"5m"`
+	TOTPIssuer           string        `yaml:"totp_issuer" env:"AUTH_TOTP_ISSUER" env-default:"SocialNetwork"`
+	// Admins lists the ids of users who are given the admin role on startup.
+	Admins []string `yaml:"admins" env:"AUTH_ADMINS" env-separator:","`
+}
+
+type Mail struct {
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER" env-default:"file"`
+	From         string `yaml:"from" env:"MAIL_FROM" env-default:"noreply@socialnetwork.local"`
+	Dir          string `yaml:"dir" env:"MAIL_DIR" env-default:"var/mail"`
+	SMTPHost     string `yaml:"smtp_host" env:"MAIL_SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" env:"MAIL_SMTP_PORT" env-default:"587"`
+	SMTPUsername string `yaml:"smtp_username" env:"MAIL_SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"MAIL_SMTP_PASSWORD"`
+}
+
+type Password struct {
+	MinLength        int    `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" env-default:"8"`
+	BreachedListPath string `yaml:"breached_list_path" env:"PASSWORD_BREACHED_LIST_PATH"`
+	// Algorithm hashes new passwords: argon2id or bcrypt. Hashes made with the other one still verify
+	// and are replaced on the next sign in, as are hashes made with other parameters.
+	Algorithm  string `yaml:"algorithm" env:"PASSWORD_ALGORITHM" env-default:"argon2id"`
+	BcryptCost int    `yaml:"bcrypt_cost" env:"PASSWORD_BCRYPT_COST" env-default:"10"`
+	// Argon2Memory is in KiB.
+	Argon2Memory      uint32 `yaml:"argon2_memory" env:"PASSWORD_ARGON2_MEMORY" env-default:"65536"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" env:"PASSWORD_ARGON2_ITERATIONS" env-default:"3"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" env:"PASSWORD_ARGON2_PARALLELISM" env-default:"2"`
+}
+
+type LoginThrottle struct {
+	FreeAttempts     int           `yaml:"free_attempts" env:"LOGIN_THROTTLE_FREE_ATTEMPTS" env-default:"3"`
+	IPFreeAttempts   int           `yaml:"ip_free_attempts" env:"LOGIN_THROTTLE_IP_FREE_ATTEMPTS" env-default:"50"`
+	BaseDelay        time.Duration `yaml:"base_delay" env:"LOGIN_THROTTLE_BASE_DELAY" env-default:"1s"`
+	MaxDelay         time.Duration `yaml:"max_delay" env:"LOGIN_THROTTLE_MAX_DELAY" env-default:"5m"`
+	LockoutThreshold int           `yaml:"lockout_threshold" env:"LOGIN_THROTTLE_LOCKOUT_THRESHOLD" env-default:"10"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration" env:"LOGIN_THROTTLE_LOCKOUT_DURATION" env-default:"15m"`
+	Window           time.Duration `yaml:"window" env:"LOGIN_THROTTLE_WINDOW" env-default:"1h"`
+}
+
+type MagicLink struct {
+	TTL time.Duration `yaml:"ttl" env:"MAGIC_LINK_TTL" env-default:"5m"`
+	// MaxRequests links may be sent to an address within Window.
+	MaxRequests int           `yaml:"max_requests" env:"MAGIC_LINK_MAX_REQUESTS" env-default:"3"`
+	Window      time.Duration `yaml:"window" env:"MAGIC_LINK_WINDOW" env-default:"15m"`
+}
+
+type RateLimit struct {
+	// PublicReadRate is how many requests per second one caller may make to endpoints
+	// that work without authentication, after a burst of PublicReadBurst. Zero turns the limit off.
+	PublicReadRate  float64 `yaml:"public_read_rate" env:"RATE_LIMIT_PUBLIC_READ_RATE" env-default:"5"`
+	PublicReadBurst int     `yaml:"public_read_burst" env:"RATE_LIMIT_PUBLIC_READ_BURST" env-default:"20"`
+}
+
+type Timeline struct {
+	// FanOut writes posts to their followers' timelines in the background and serves feeds from them.
+	// Without it every feed is queried from the posts of the followed users.
+	FanOut bool `yaml:"fan_out" env:"TIMELINE_FAN_OUT" env-default:"true"`
+	// CelebrityFollowers is the follower count from which posts are read when a feed loads instead.
+	CelebrityFollowers int           `yaml:"celebrity_followers" env:"TIMELINE_CELEBRITY_FOLLOWERS" env-default:"10000"`
+	BatchSize          int           `yaml:"batch_size" env:"TIMELINE_BATCH_SIZE" env-default:"100"`
+	PollInterval       time.Duration `yaml:"poll_interval" env:"TIMELINE_POLL_INTERVAL" env-default:"5s"`
+}
+
+type Reactions struct {
+	// Allowed lists the emoji users may react to posts with.
+	Allowed []string `yaml:"allowed" env:"REACTIONS_ALLOWED" env-separator:","`
+}
+
+type OIDC struct {
+	// StateTTL is how long a user has to come back from the provider's consent page.
+	StateTTL  time.Duration  `yaml:"state_ttl" env:"OIDC_STATE_TTL" env-default:"10m"`
+	Providers []OIDCProvider `yaml:"providers"`
+}
+
+// OIDCProvider is an OpenID provider users can sign in with, such as Google or a company's Keycloak.
+type OIDCProvider struct {
+	// Name appears in the sign in URLs, /auth/oidc/<name>.
+	Name         string `yaml:"name"`
+	IssuerURL    string `yaml:"issuer_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// ClientSecretEnv names an environment variable to read the client secret from instead.
+	ClientSecretEnv string `yaml:"client_secret_env"`
+	// RedirectURL is registered with the provider and has to lead to /auth/oidc/<name>/callback.
+	RedirectURL string   `yaml:"redirect_url"`
+	Scopes      []string `yaml:"scopes"`
 }
 
 func MustLoad() *Config {
@@ -2,7 +2,9 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -39,13 +41,22 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
+type Config struct {
+	V1 v1.Config
+	// TrustedProxies are the peers whose X-Forwarded-For and X-Real-IP headers are believed.
+	// Requests from anywhere else are attributed to the address they came from.
+	TrustedProxies []netip.Prefix
+}
+
 type Handler struct {
 	services *service.Service
+	cfg      Config
 }
 
-func NewHandler(services *service.Service) *Handler {
+func NewHandler(services *service.Service, cfg Config) *Handler {
 	return &Handler{
 		services: services,
+		cfg:      cfg,
 	}
 }
 
@@ -53,21 +64,35 @@ func (h *Handler) Init() *chi.Mux {
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
+	router.Use(realIP(h.cfg.TrustedProxies))
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.Timeout(60 * time.Second))
 
 	router.Route("/api", func(r chi.Router) {
 		r.Route("/v1", func(r chi.Router) {
-			h1 := v1.NewHandler(h.services)
+			h1 := v1.NewHandler(h.services, h.cfg.V1)
 			h1.Init(r.(*chi.Mux))
 		})
 	})
 
+	router.Get("/.well-known/jwks.json", h.jwks)
+
 	router.Get("/api/docs/*", httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8080/api/docs/doc.json"),
 	))
 
 	return router
 }
+
+// @Summary JSON Web Key Set
+// @Description Public keys that verify the tokens issued by this service
+// @Tags auth
+// @Produce json
+// @Success 200 {object} keyring.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) jwks(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(h.services.Auth.JWKS())
+}
@@ -1,14 +1,19 @@
 package http
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/defskela/SocialNetwork/internal/config"
 	"github.com/defskela/SocialNetwork/internal/service"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
 )
 
 func TestNewServer(t *testing.T) {
@@ -28,8 +33,79 @@ func TestNewServer(t *testing.T) {
 
 func TestHandler_Init(t *testing.T) {
 	services := &service.Service{}
-	h := NewHandler(services)
+	h := NewHandler(services, Config{})
 
 	router := h.Init()
 	assert.NotNil(t, router)
 }
+
+type stubAuthService struct {
+	service.AuthService
+	jwks keyring.JWKS
+}
+
+func (s *stubAuthService) JWKS() keyring.JWKS {
+	return s.jwks
+}
+
+func TestHandler_JWKS(t *testing.T) {
+	ring, err := keyring.FromFiles("../../../certs/local/private.pem", "../../../certs/local/public.pem", keyring.RS256)
+	require.NoError(t, err)
+
+	h := NewHandler(&service.Service{Auth: &stubAuthService{jwks: ring.JWKS()}}, Config{})
+
+	w := httptest.NewRecorder()
+	h.Init().ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/jwks.json", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var set keyring.JWKS
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, ring.Active().ID, set.Keys[0].Kid)
+}
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7:5000"},
+		{
+			"untrusted peer forwarding", "203.0.113.7:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7:5000",
+		},
+		{"untrusted peer real ip", "203.0.113.7:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "203.0.113.7:5000"},
+		{"trusted proxy", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{
+			"spoofed hops are skipped", "10.0.0.2:5000",
+			map[string]string{"X-Forwarded-For": "192.0.2.9, 198.51.100.1, 10.0.0.3"}, "198.51.100.1",
+		},
+		{"trusted proxy real ip", "10.0.0.2:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"trusted proxy without headers", "10.0.0.2:5000", nil, "10.0.0.2:5000"},
+		{"malformed header", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "nonsense"}, "10.0.0.2:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := realIP(trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	errUserNotFound    = "user not found"
+	errRoleNotFound    = "role not found"
+	errRoleNotAssigned = "role not assigned"
+)
+
+// @Summary Unlock a user
+// @Description Clear the failed sign in attempts of a user, lifting a lockout. Requires users:unlock
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/users/{id}/unlock [post]
+func (h *Handler) unlockUser(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.UnlockAccount(r.Context(), actorID, userID); err != nil {
+		switch err.Error() {
+		case errForbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errUserNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Assign a role
+// @Description Grant a role, such as admin or moderator, to a user. Requires roles:manage
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param role path string true "Role name"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/users/{id}/roles/{role} [put]
+func (h *Handler) assignRole(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.AssignRole(r.Context(), actorID, userID, chi.URLParam(r, "role")); err != nil {
+		switch err.Error() {
+		case errForbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errUserNotFound, errRoleNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Remove a role
+// @Description Take a role away from a user. Requires roles:manage
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param role path string true "Role name"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/users/{id}/roles/{role} [delete]
+func (h *Handler) removeRole(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.RemoveRole(r.Context(), actorID, userID, chi.URLParam(r, "role")); err != nil {
+		switch err.Error() {
+		case errForbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errRoleNotAssigned:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -3,18 +3,26 @@ package v1
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/defskela/SocialNetwork/internal/config"
+	"github.com/defskela/SocialNetwork/internal/repository"
 	"github.com/defskela/SocialNetwork/internal/repository/postgres"
 	"github.com/defskela/SocialNetwork/internal/service"
 	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
+	"github.com/defskela/SocialNetwork/pkg/totp"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/suite"
 )
@@ -25,11 +33,68 @@ const (
 	testDBHost      = "localhost"
 )
 
+func testAuthConfig(privKeyPath, pubKeyPath string) service.AuthConfig {
+	return service.AuthConfig{
+		Algorithm:          keyring.RS256,
+		PrivateKeyPath:     privKeyPath,
+		PublicKeyPath:      pubKeyPath,
+		AccessTokenTTL:     time.Hour,
+		Issuer:             "http://localhost:8080",
+		Audience:           "social-network-api",
+		Leeway:             time.Second,
+		RefreshTokenTTL:    24 * time.Hour,
+		PublicURL:          "http://localhost:8080",
+		PasswordResetTTL:   time.Hour,
+		RevocationCacheTTL: time.Second,
+		PasswordPolicy:     service.PasswordPolicyConfig{MinLength: 8},
+		MFAChallengeTTL:    5 * time.Minute,
+		TOTPIssuer:         "SocialNetwork",
+		MagicLink:          service.MagicLinkConfig{TTL: 5 * time.Minute, MaxRequests: 3, Window: 15 * time.Minute},
+	}
+}
+
+func testRepository(pool *pgxpool.Pool) *repository.Repository {
+	return repository.NewRepository(
+		postgres.NewUserRepository(pool),
+		postgres.NewPostRepository(pool),
+		postgres.NewRefreshTokenRepository(pool),
+		postgres.NewSessionRepository(pool),
+		postgres.NewPasswordResetRepository(pool),
+		postgres.NewMFARepository(pool),
+		postgres.NewLoginThrottleRepository(pool),
+		postgres.NewPersonalAccessTokenRepository(pool),
+		postgres.NewRoleRepository(pool),
+		postgres.NewOIDCRepository(pool),
+		postgres.NewMagicLinkRepository(pool),
+		postgres.NewFollowRepository(pool),
+		postgres.NewBlockRepository(pool),
+		postgres.NewTimelineRepository(pool),
+		postgres.NewLikeRepository(pool),
+		postgres.NewReactionRepository(pool),
+	)
+}
+
+// tokenFromMessage extracts the token query parameter from a link in an email body.
+func tokenFromMessage(body string) string {
+	match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(body)
+	if match == nil {
+		return ""
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		return ""
+	}
+
+	return token
+}
+
 type AuthHandlerSuite struct {
 	suite.Suite
 	pool        *pgxpool.Pool
 	handler     *Handler
 	authService service.AuthService
+	mailer      *mailer.Memory
 	privKeyPath string
 	pubKeyPath  string
 	router      *chi.Mux
@@ -54,14 +119,18 @@ func (s *AuthHandlerSuite) SetupTest() {
 	s.privKeyPath = testPrivKeyPath
 	s.pubKeyPath = testPubKeyPath
 
-	repo := postgres.NewUserRepository(s.pool)
+	s.mailer = mailer.NewMemory()
 
 	var err error
-	s.authService, err = service.NewAuthService(repo, time.Hour, s.privKeyPath, s.pubKeyPath)
+	s.authService, err = service.NewAuthService(
+		testRepository(s.pool),
+		s.mailer,
+		testAuthConfig(s.privKeyPath, s.pubKeyPath),
+	)
 	s.Require().NoError(err)
 
 	services := &service.Service{Auth: s.authService}
-	s.handler = NewHandler(services)
+	s.handler = NewHandler(services, Config{})
 
 	s.router = chi.NewRouter()
 	s.handler.Init(s.router)
@@ -182,6 +251,397 @@ func (s *AuthHandlerSuite) TestSignIn() {
 	}
 }
 
+func (s *AuthHandlerSuite) TestRefresh() {
+	signUpInput := service.SignUpInput{
+		Username: "testrefresh_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Email:    "testrefresh_" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@test.com",
+		Password: "password",
+	}
+
+	_, err := s.authService.SignUp(context.Background(), signUpInput)
+	s.Require().NoError(err)
+
+	tokens, err := s.authService.SignIn(context.Background(), service.SignInInput{
+		Email:    signUpInput.Email,
+		Password: signUpInput.Password,
+	})
+	s.Require().NoError(err)
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "OK",
+			inputBody:            `{"refresh_token": "` + tokens.RefreshToken + `"}`,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `"refresh_token":`,
+		},
+		{
+			name:                 "Reused Token",
+			inputBody:            `{"refresh_token": "` + tokens.RefreshToken + `"}`,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: "refresh token reused",
+		},
+		{
+			name:                 "Unknown Token",
+			inputBody:            `{"refresh_token": "unknown"}`,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: "invalid refresh token",
+		},
+		{
+			name:                 "Validation Error",
+			inputBody:            `{"refresh_token": ""}`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: "Field validation for",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBufferString(tt.inputBody))
+
+			s.router.ServeHTTP(w, req)
+
+			s.Equal(tt.expectedStatusCode, w.Code)
+			s.Contains(w.Body.String(), tt.expectedResponseBody)
+		})
+	}
+}
+
+func (s *AuthHandlerSuite) TestLogout() {
+	signUpInput := service.SignUpInput{
+		Username: "testlogout_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Email:    "testlogout_" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@test.com",
+		Password: "password",
+	}
+
+	_, err := s.authService.SignUp(context.Background(), signUpInput)
+	s.Require().NoError(err)
+
+	signInInput := service.SignInInput{Email: signUpInput.Email, Password: signUpInput.Password}
+	first, err := s.authService.SignIn(context.Background(), signInInput)
+	s.Require().NoError(err)
+	second, err := s.authService.SignIn(context.Background(), signInInput)
+	s.Require().NoError(err)
+
+	tests := []struct {
+		name                 string
+		path                 string
+		token                string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:               "Logout",
+			path:               "/auth/logout",
+			token:              first.AccessToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                 "Revoked Session",
+			path:                 "/auth/logout",
+			token:                first.AccessToken,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: "session revoked",
+		},
+		{
+			name:               "Logout All",
+			path:               "/auth/logout-all",
+			token:              second.AccessToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                 "Revoked By Logout All",
+			path:                 "/auth/logout-all",
+			token:                second.AccessToken,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: "session revoked",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", tt.path, http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			s.router.ServeHTTP(w, req)
+
+			s.Equal(tt.expectedStatusCode, w.Code)
+			s.Contains(w.Body.String(), tt.expectedResponseBody)
+		})
+	}
+}
+
+func (s *AuthHandlerSuite) TestVerifyEmail() {
+	signUpInput := service.SignUpInput{
+		Username: "testverify_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Email:    "testverify_" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@test.com",
+		Password: "password",
+	}
+
+	_, err := s.authService.SignUp(context.Background(), signUpInput)
+	s.Require().NoError(err)
+
+	msg, ok := s.mailer.Last(signUpInput.Email)
+	s.Require().True(ok)
+	token := tokenFromMessage(msg.Body)
+	s.Require().NotEmpty(token)
+
+	tests := []struct {
+		name                 string
+		path                 string
+		inputBody            string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:               "OK",
+			path:               "/auth/verify-email",
+			inputBody:          `{"token": "` + token + `"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                 "Invalid Token",
+			path:                 "/auth/verify-email",
+			inputBody:            `{"token": "not-a-token"}`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: "invalid verification token",
+		},
+		{
+			name:               "Resend Unknown Email",
+			path:               "/auth/verify-email/resend",
+			inputBody:          `{"email": "nobody_` + strconv.FormatInt(time.Now().UnixNano(), 10) + `@test.com"}`,
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:                 "Resend Validation Error",
+			path:                 "/auth/verify-email/resend",
+			inputBody:            `{"email": "not-an-email"}`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: "Field validation for",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", tt.path, bytes.NewBufferString(tt.inputBody))
+
+			s.router.ServeHTTP(w, req)
+
+			s.Equal(tt.expectedStatusCode, w.Code)
+			s.Contains(w.Body.String(), tt.expectedResponseBody)
+		})
+	}
+}
+
+func (s *AuthHandlerSuite) TestMFA() {
+	uniqueIdx := strconv.FormatInt(time.Now().UnixNano(), 10)
+	signUpInput := service.SignUpInput{
+		Username: "testmfa_" + uniqueIdx,
+		Email:    "testmfa_" + uniqueIdx + "@test.com",
+		Password: "password",
+	}
+
+	_, err := s.authService.SignUp(context.Background(), signUpInput)
+	s.Require().NoError(err)
+
+	tokens, err := s.authService.SignIn(context.Background(), service.SignInInput{
+		Email:    signUpInput.Email,
+		Password: signUpInput.Password,
+	})
+	s.Require().NoError(err)
+
+	req := httptest.NewRequest("POST", "/users/me/mfa/totp", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var enrollment service.TOTPEnrollment
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &enrollment))
+	s.Contains(enrollment.URI, "otpauth://totp/")
+
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	s.Require().NoError(err)
+
+	req = httptest.NewRequest("POST", "/users/me/mfa/totp/confirm", bytes.NewBufferString(`{"code": "`+code+`"}`))
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var recovery service.RecoveryCodes
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &recovery))
+	s.Require().NotEmpty(recovery.Codes)
+
+	loginBody := `{"email": "` + signUpInput.Email + `", "password": "password"}`
+	req = httptest.NewRequest("POST", "/auth/login", bytes.NewBufferString(loginBody))
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.NotContains(w.Body.String(), `"access_token"`)
+
+	var challenge service.Tokens
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &challenge))
+	s.True(challenge.MFARequired)
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "Invalid Token",
+			inputBody:            `{"mfa_token": "garbage", "code": "` + recovery.Codes[0] + `"}`,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: "invalid mfa token",
+		},
+		{
+			name:                 "Invalid Code",
+			inputBody:            `{"mfa_token": "` + challenge.MFAToken + `", "code": "nope"}`,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: "invalid mfa code",
+		},
+		{
+			name:                 "Recovery Code",
+			inputBody:            `{"mfa_token": "` + challenge.MFAToken + `", "code": "` + recovery.Codes[0] + `"}`,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `"access_token":`,
+		},
+		{
+			name:                 "Recovery Code Reused",
+			inputBody:            `{"mfa_token": "` + challenge.MFAToken + `", "code": "` + recovery.Codes[0] + `"}`,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: "invalid mfa code",
+		},
+		{
+			name:                 "Validation Error",
+			inputBody:            `{"mfa_token": ""}`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: "Field validation for",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/auth/login/mfa", bytes.NewBufferString(tt.inputBody))
+
+			s.router.ServeHTTP(w, req)
+
+			s.Equal(tt.expectedStatusCode, w.Code)
+			s.Contains(w.Body.String(), tt.expectedResponseBody)
+		})
+	}
+}
+
+func (s *AuthHandlerSuite) TestSignIn_Throttled() {
+	cfg := testAuthConfig(s.privKeyPath, s.pubKeyPath)
+	cfg.LoginThrottle = service.LoginThrottleConfig{
+		FreeAttempts:     0,
+		IPFreeAttempts:   100,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 5,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	}
+
+	authService, err := service.NewAuthService(testRepository(s.pool), s.mailer, cfg)
+	s.Require().NoError(err)
+
+	router := chi.NewRouter()
+	NewHandler(&service.Service{Auth: authService}, Config{}).Init(router)
+
+	signUpInput := service.SignUpInput{
+		Username: "testthrottle_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Email:    "testthrottle_" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@test.com",
+		Password: "password",
+	}
+
+	_, err = authService.SignUp(context.Background(), signUpInput)
+	s.Require().NoError(err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/auth/login",
+		bytes.NewBufferString(`{"email": "`+signUpInput.Email+`", "password": "wrongpassword"}`))
+	router.ServeHTTP(w, req)
+	s.Equal(http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/auth/login",
+		bytes.NewBufferString(`{"email": "`+signUpInput.Email+`", "password": "password"}`))
+	router.ServeHTTP(w, req)
+	s.Equal(http.StatusTooManyRequests, w.Code)
+	s.Contains(w.Body.String(), "too many login attempts")
+
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	s.Require().NoError(err)
+	s.InDelta(60, retryAfter, 2)
+}
+
+func (s *AuthHandlerSuite) TestRoles() {
+	signIn := func(isAdmin bool) (string, uuid.UUID) {
+		uniqueID := strconv.FormatInt(time.Now().UnixNano(), 10)
+		input := service.SignUpInput{
+			Username: "testroles_" + uniqueID,
+			Email:    "testroles_" + uniqueID + "@test.com",
+			Password: "password",
+		}
+
+		userID, err := s.authService.SignUp(context.Background(), input)
+		s.Require().NoError(err)
+
+		if isAdmin {
+			err = testRepository(s.pool).Role.Assign(context.Background(), userID, service.RoleAdmin)
+			s.Require().NoError(err)
+		}
+
+		tokens, err := s.authService.SignIn(context.Background(), service.SignInInput{
+			Email:    input.Email,
+			Password: input.Password,
+		})
+		s.Require().NoError(err)
+
+		return tokens.AccessToken, userID
+	}
+
+	adminToken, _ := signIn(true)
+	userToken, userID := signIn(false)
+
+	tests := []struct {
+		name         string
+		method       string
+		token        string
+		role         string
+		expectedCode int
+	}{
+		{"Not an admin", "PUT", userToken, service.RoleModerator, http.StatusForbidden},
+		{"Assign", "PUT", adminToken, service.RoleModerator, http.StatusOK},
+		{"Assign again", "PUT", adminToken, service.RoleModerator, http.StatusOK},
+		{"Unknown role", "PUT", adminToken, "owner", http.StatusNotFound},
+		{"Remove", "DELETE", adminToken, service.RoleModerator, http.StatusOK},
+		{"Remove missing role", "DELETE", adminToken, service.RoleModerator, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/admin/users/"+userID.String()+"/roles/"+tt.role, http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			s.router.ServeHTTP(w, req)
+			s.Equal(tt.expectedCode, w.Code)
+		})
+	}
+}
+
 func TestAuthHandlerSuite(t *testing.T) {
 	suite.Run(t, new(AuthHandlerSuite))
 }
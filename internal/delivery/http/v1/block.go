@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/service"
+)
+
+const (
+	errCannotBlockSelf = "cannot block yourself"
+	errCannotMuteSelf  = "cannot mute yourself"
+)
+
+// @Summary Block a user
+// @Description Block a user. Neither of you can follow the other or see the other's profile and posts,
+// @Description and the follows between you are removed. Blocking someone who is already blocked is not an error
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/block [post]
+func (h *Handler) block(w http.ResponseWriter, r *http.Request) {
+	h.restrict(w, r, h.services.Block.Block)
+}
+
+// @Summary Unblock a user
+// @Description Lift a block. Unblocking someone who is not blocked is not an error
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/block [delete]
+func (h *Handler) unblock(w http.ResponseWriter, r *http.Request) {
+	h.restrict(w, r, h.services.Block.Unblock)
+}
+
+// @Summary Mute a user
+// @Description Hide a user's posts from your feeds. They are not told and can still see your posts.
+// @Description Muting someone who is already muted is not an error
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/mute [post]
+func (h *Handler) mute(w http.ResponseWriter, r *http.Request) {
+	h.restrict(w, r, h.services.Block.Mute)
+}
+
+// @Summary Unmute a user
+// @Description Show a muted user's posts in your feeds again. Unmuting someone who is not muted is not an error
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/mute [delete]
+func (h *Handler) unmute(w http.ResponseWriter, r *http.Request) {
+	h.restrict(w, r, h.services.Block.Unmute)
+}
+
+// @Summary List blocked users
+// @Description List the users the current user has blocked, newest first
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Success 200 {object} service.RestrictedPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/blocks [get]
+func (h *Handler) listBlocked(w http.ResponseWriter, r *http.Request) {
+	h.listRestricted(w, r, h.services.Block.ListBlocked)
+}
+
+// @Summary List muted users
+// @Description List the users the current user has muted, newest first
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Success 200 {object} service.RestrictedPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/mutes [get]
+func (h *Handler) listMuted(w http.ResponseWriter, r *http.Request) {
+	h.listRestricted(w, r, h.services.Block.ListMuted)
+}
+
+// restrict blocks, mutes, or lifts either on the user in the path on behalf of the current user.
+func (h *Handler) restrict(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(ctx context.Context, userID, otherID uuid.UUID) error,
+) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	otherID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := change(r.Context(), userID, otherID); err != nil {
+		switch err.Error() {
+		case errCannotBlockSelf, errCannotMuteSelf:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errUserNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) listRestricted(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(ctx context.Context, userID uuid.UUID, page service.PageInput) (*service.RestrictedPage, error),
+) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := pageInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := list(r.Context(), userID, page)
+	if err != nil {
+		if err.Error() == errInvalidCursor {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
@@ -5,17 +5,20 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/defskela/SocialNetwork/internal/config"
 	"github.com/defskela/SocialNetwork/internal/entity"
-	"github.com/defskela/SocialNetwork/internal/repository/postgres"
 	"github.com/defskela/SocialNetwork/internal/service"
 	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/suite"
 )
@@ -46,16 +49,21 @@ func (s *E2ESuite) SetupTest() {
 	}
 	s.pool = pool
 
-	repo := postgres.NewUserRepository(s.pool)
-	s.authService, err = service.NewAuthService(repo, time.Hour, privKeyPath, pubKeyPath)
+	repos := testRepository(s.pool)
+
+	s.authService, err = service.NewAuthService(repos, mailer.NewMemory(), testAuthConfig(privKeyPath, pubKeyPath))
 	s.Require().NoError(err)
-	s.userService = service.NewUserService(repo)
+	s.userService = service.NewUserService(repos)
 
-	postRepo := postgres.NewPostRepository(s.pool)
-	postService := service.NewPostService(postRepo)
+	postService := service.NewPostService(repos, service.PostConfig{})
 
-	services := &service.Service{Auth: s.authService, User: s.userService, Post: postService}
-	s.handler = NewHandler(services)
+	services := &service.Service{
+		Auth:   s.authService,
+		User:   s.userService,
+		Post:   postService,
+		Follow: service.NewFollowService(repos),
+	}
+	s.handler = NewHandler(services, Config{})
 	s.router = chi.NewRouter()
 	s.handler.Init(s.router)
 }
@@ -168,6 +176,310 @@ func (s *E2ESuite) TestPostCRUD() {
 	s.Equal(http.StatusNotFound, w.Code)
 }
 
+func (s *E2ESuite) TestFeed() {
+	login := func(prefix string) (string, uuid.UUID) {
+		username := prefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+		id, err := s.authService.SignUp(context.Background(), service.SignUpInput{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+
+		tokens, err := s.authService.SignIn(context.Background(), service.SignInInput{
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+		return tokens.AccessToken, id
+	}
+	readerToken, _ := login("e2e_reader_")
+	authorToken, authorID := login("e2e_author_")
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	s.Require().Equal(http.StatusOK, do("POST", "/users/"+authorID.String()+"/follow", readerToken, "").Code)
+	for _, content := range []string{"first", "second"} {
+		s.Require().Equal(http.StatusCreated, do("POST", "/posts", authorToken, `{"content": "`+content+`"}`).Code)
+	}
+
+	w := do("GET", "/feed?limit=1", readerToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var page service.PostPage
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Posts, 1)
+	s.Equal("second", page.Posts[0].Content)
+	s.Require().NotEmpty(page.NextCursor)
+
+	w = do("GET", "/feed?limit=1&cursor="+page.NextCursor, readerToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	page = service.PostPage{}
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Posts, 1)
+	s.Equal("first", page.Posts[0].Content)
+	s.Empty(page.NextCursor)
+
+	w = do("GET", "/feed", authorToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Empty(page.Posts)
+
+	s.Equal(http.StatusBadRequest, do("GET", "/feed?cursor=garbage", readerToken, "").Code)
+	s.Equal(http.StatusUnauthorized, do("GET", "/feed", "", "").Code)
+}
+
+func (s *E2ESuite) TestUserPosts() {
+	login := func(prefix string) (string, uuid.UUID) {
+		username := prefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+		id, err := s.authService.SignUp(context.Background(), service.SignUpInput{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+
+		tokens, err := s.authService.SignIn(context.Background(), service.SignInInput{
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+		return tokens.AccessToken, id
+	}
+	authorToken, authorID := login("e2e_author_")
+	readerToken, _ := login("e2e_reader_")
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, content := range []string{"first", "second"} {
+		s.Require().Equal(http.StatusCreated, do("POST", "/posts", authorToken, `{"content": "`+content+`"}`).Code)
+	}
+
+	path := "/users/" + authorID.String() + "/posts"
+	w := do("GET", path+"?limit=1", readerToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var page service.PostPage
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Posts, 1)
+	s.Equal("second", page.Posts[0].Content)
+	s.NotEmpty(page.NextCursor)
+
+	w = do("GET", path+"?until="+url.QueryEscape(page.Posts[0].CreatedAt.Format(time.RFC3339Nano)), readerToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	page = service.PostPage{}
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Posts, 1)
+	s.Equal("first", page.Posts[0].Content)
+
+	reply := `{"content": "reply", "reply_to_id": "` + page.Posts[0].ID.String() + `"}`
+	s.Require().Equal(http.StatusCreated, do("POST", "/posts", authorToken, reply).Code)
+	for query, want := range map[string]int{"": 2, "?include_replies=false": 2, "?include_replies=true": 3} {
+		w = do("GET", path+query, readerToken, "")
+		s.Require().Equal(http.StatusOK, w.Code)
+		page = service.PostPage{}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+		s.Len(page.Posts, want, query)
+	}
+
+	s.Equal(http.StatusBadRequest, do("GET", path+"?include_replies=maybe", readerToken, "").Code)
+	s.Equal(http.StatusBadRequest, do("GET", path+"?since=yesterday", readerToken, "").Code)
+	s.Equal(http.StatusNotFound, do("GET", "/users/"+uuid.NewString()+"/posts", readerToken, "").Code)
+
+	s.Require().Equal(http.StatusOK, do("PATCH", "/users/me", authorToken, `{"is_private": true}`).Code)
+	s.Equal(http.StatusNotFound, do("GET", path, readerToken, "").Code)
+	s.Equal(http.StatusOK, do("GET", path, authorToken, "").Code)
+}
+
+func (s *E2ESuite) TestLikes() {
+	tokens := make([]string, 2)
+	for i := range tokens {
+		username := "e2e_liker_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		_, err := s.authService.SignUp(context.Background(), service.SignUpInput{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+
+		pair, err := s.authService.SignIn(context.Background(), service.SignInInput{
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+		tokens[i] = pair.AccessToken
+	}
+	author, liker := tokens[0], tokens[1]
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/posts", author, `{"content": "Like me"}`)
+	s.Require().Equal(http.StatusCreated, w.Code)
+	var created map[string]string
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&created))
+	path := "/posts/" + created["id"]
+
+	for i := 0; i < 2; i++ {
+		w = do("POST", path+"/like", liker, "")
+		s.Require().Equal(http.StatusOK, w.Code)
+		var result service.LikeResult
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&result))
+		s.Equal(service.LikeResult{LikeCount: 1, LikedByMe: true}, result)
+	}
+
+	w = do("GET", path, liker, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var post entity.Post
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&post))
+	s.Equal(1, post.LikeCount)
+	s.True(post.LikedByMe)
+
+	w = do("GET", path+"/likes", author, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var likes service.LikePage
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&likes))
+	s.Len(likes.Users, 1)
+
+	w = do("DELETE", path+"/like", liker, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `"like_count":0`)
+
+	s.Equal(http.StatusNotFound, do("POST", "/posts/"+uuid.NewString()+"/like", liker, "").Code)
+	s.Equal(http.StatusBadRequest, do("GET", "/posts/not-a-uuid/likes", liker, "").Code)
+}
+
+func (s *E2ESuite) TestReactions() {
+	tokens := make([]string, 2)
+	for i := range tokens {
+		username := "e2e_reactor_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		_, err := s.authService.SignUp(context.Background(), service.SignUpInput{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+
+		pair, err := s.authService.SignIn(context.Background(), service.SignInInput{
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+		tokens[i] = pair.AccessToken
+	}
+	author, reactor := tokens[0], tokens[1]
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/posts", author, `{"content": "React to me"}`)
+	s.Require().Equal(http.StatusCreated, w.Code)
+	var created map[string]string
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&created))
+	path := "/posts/" + created["id"]
+	thumbsUp := "/reactions/" + url.PathEscape("👍")
+
+	w = do("GET", path, reactor, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `"reactions":{}`)
+	s.Contains(w.Body.String(), `"my_reactions":[]`)
+
+	w = do("POST", path+thumbsUp, reactor, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var result service.ReactionResult
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&result))
+	s.Equal(map[string]int{"👍": 1}, result.Reactions)
+	s.Equal([]string{"👍"}, result.MyReactions)
+
+	w = do("GET", path, author, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var post entity.Post
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&post))
+	s.Equal(map[string]int{"👍": 1}, post.Reactions)
+	s.Empty(post.MyReactions)
+
+	w = do("DELETE", path+thumbsUp, reactor, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `"reactions":{}`)
+
+	s.Equal(http.StatusBadRequest, do("POST", path+"/reactions/"+url.PathEscape("🦄"), reactor, "").Code)
+	s.Equal(http.StatusNotFound, do("POST", "/posts/"+uuid.NewString()+thumbsUp, reactor, "").Code)
+}
+
+// A moderator's personal access token acts with the moderator's own rights only.
+func (s *E2ESuite) TestModeratorPersonalAccessToken() {
+	ctx := context.Background()
+	login := func(prefix string) (string, uuid.UUID) {
+		username := prefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+		id, err := s.authService.SignUp(ctx, service.SignUpInput{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+
+		pair, err := s.authService.SignIn(ctx, service.SignInInput{
+			Email:    username + "@example.com",
+			Password: testPassword,
+		})
+		s.Require().NoError(err)
+		return pair.AccessToken, id
+	}
+	authorToken, author := login("e2e_pat_author_")
+	moderatorToken, moderator := login("e2e_pat_moderator_")
+	s.Require().NoError(testRepository(s.pool).Role.Assign(ctx, moderator, service.RoleModerator))
+
+	pat, err := s.authService.CreatePersonalAccessToken(ctx, moderator, service.CreatePersonalAccessTokenInput{
+		Name:   "bot",
+		Scopes: []string{service.ScopePostsRead, service.ScopePostsWrite},
+	})
+	s.Require().NoError(err)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/posts", authorToken, `{"content": "Moderate me"}`)
+	s.Require().Equal(http.StatusCreated, w.Code)
+	var created map[string]string
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&created))
+	path := "/posts/" + created["id"]
+
+	s.Equal(http.StatusForbidden, do("PATCH", path, pat.Token, `{"content": "[removed]"}`).Code)
+	s.Equal(http.StatusForbidden, do("DELETE", path, pat.Token, "").Code)
+
+	s.Require().Equal(http.StatusOK, do("PATCH", "/users/me", authorToken, `{"is_private": true}`).Code)
+	s.Equal(http.StatusNotFound, do("GET", path, pat.Token, "").Code)
+	s.Equal(http.StatusNotFound, do("GET", "/users/"+author.String()+"/posts", pat.Token, "").Code)
+
+	s.Equal(http.StatusOK, do("GET", path, moderatorToken, "").Code)
+	s.Equal(http.StatusOK, do("PATCH", path, moderatorToken, `{"content": "[removed]"}`).Code)
+}
+
 func TestE2ESuite(t *testing.T) {
 	suite.Run(t, new(E2ESuite))
 }
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// @Summary Get the home feed
+// @Description List the posts of the users the current user follows, newest first. Muted users are left out
+// @Tags posts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Success 200 {object} service.PostPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /feed [get]
+func (h *Handler) getFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := pageInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.services.Post.Feed(r.Context(), userID, page)
+	if err != nil {
+		if err.Error() == errInvalidCursor {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
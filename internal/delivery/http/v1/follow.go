@@ -0,0 +1,293 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/service"
+)
+
+const (
+	errCannotFollowSelf = "cannot follow yourself"
+	errInvalidCursor    = "invalid cursor"
+	errInvalidLimit     = "invalid limit"
+
+	errFollowRequestNotFound = "follow request not found"
+)
+
+// @Summary Follow a user
+// @Description Follow a user. Following a private account sends a follow request that the owner has to accept.
+// @Description Following someone who is already followed is not an error
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} service.FollowResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/follow [post]
+func (h *Handler) follow(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	followeeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.services.Follow.Follow(r.Context(), userID, followeeID)
+	if err != nil {
+		switch err.Error() {
+		case errCannotFollowSelf:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errUserNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(service.FollowResult{Status: status})
+}
+
+// @Summary Unfollow a user
+// @Description Stop following a user or withdraw a follow request.
+// @Description Unfollowing someone who is not followed is not an error
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/follow [delete]
+func (h *Handler) unfollow(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	followeeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Follow.Unfollow(r.Context(), userID, followeeID); err != nil {
+		if err.Error() == errUserNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary List followers
+// @Description List the users who follow a user, newest first. Private accounts only show them to approved followers
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Success 200 {object} service.FollowPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/followers [get]
+func (h *Handler) listFollowers(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, h.services.Follow.ListFollowers)
+}
+
+// @Summary List followings
+// @Description List the users a user follows, newest first. Private accounts only show them to approved followers
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Success 200 {object} service.FollowPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/following [get]
+func (h *Handler) listFollowing(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, h.services.Follow.ListFollowing)
+}
+
+// @Summary List follow requests
+// @Description List the pending requests to follow the current user, newest first
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Success 200 {object} service.FollowPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/follow-requests [get]
+func (h *Handler) listFollowRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := pageInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.services.Follow.ListFollowRequests(r.Context(), userID, page)
+	if err != nil {
+		if err.Error() == errInvalidCursor {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// @Summary Accept a follow request
+// @Description Let the user who sent a follow request follow the current user
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "ID of the user who sent the request"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/follow-requests/{id}/accept [post]
+func (h *Handler) acceptFollowRequest(w http.ResponseWriter, r *http.Request) {
+	h.answerFollowRequest(w, r, h.services.Follow.AcceptFollowRequest)
+}
+
+// @Summary Reject a follow request
+// @Description Drop a follow request without letting its sender follow the current user
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "ID of the user who sent the request"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/follow-requests/{id}/reject [post]
+func (h *Handler) rejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	h.answerFollowRequest(w, r, h.services.Follow.RejectFollowRequest)
+}
+
+func (h *Handler) answerFollowRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	answer func(ctx context.Context, userID, requesterID uuid.UUID) error,
+) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	requesterID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := answer(r.Context(), userID, requesterID); err != nil {
+		if err.Error() == errFollowRequestNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) listFollows(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(ctx context.Context, viewerID, userID uuid.UUID, page service.PageInput) (*service.FollowPage, error),
+) {
+	viewerID, _ := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pageInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := list(r.Context(), viewerID, userID, page)
+	if err != nil {
+		switch err.Error() {
+		case errInvalidCursor:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errUserNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// pageInput reads the cursor and limit query parameters.
+func pageInput(r *http.Request) (service.PageInput, error) {
+	page := service.PageInput{Cursor: r.URL.Query().Get("cursor")}
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return page, errors.New(errInvalidLimit)
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
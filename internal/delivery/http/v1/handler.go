@@ -3,24 +3,57 @@ package v1
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/defskela/SocialNetwork/internal/entity"
 	"github.com/defskela/SocialNetwork/internal/service"
+	"github.com/defskela/SocialNetwork/pkg/ratelimit"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
+const (
+	errInvalidRefreshToken = "invalid refresh token"
+	errRefreshTokenExpired = "refresh token expired"
+	errRefreshTokenReused  = "refresh token reused"
+	errSessionNotFound     = "session not found"
+	errInvalidVerification = "invalid verification token"
+	errInvalidResetToken   = "invalid reset token"
+	errInvalidPassword     = "invalid password"
+	errWeakPassword        = "weak password"
+)
+
+// publicReadLimiterSize is how many callers the public read limiter keeps track of.
+const publicReadLimiterSize = 100000
+
+type Config struct {
+	// PublicReadRate is how many requests per second a caller may make to the endpoints that
+	// work without authentication, after a burst of PublicReadBurst. Zero means no limit.
+	PublicReadRate  float64
+	PublicReadBurst int
+}
+
 type Handler struct {
-	services  *service.Service
-	validator *validator.Validate
+	services    *service.Service
+	validator   *validator.Validate
+	publicReads *ratelimit.Limiter
 }
 
-func NewHandler(services *service.Service) *Handler {
+func NewHandler(services *service.Service, cfg Config) *Handler {
+	v := validator.New()
+	v.RegisterAlias("scope", "oneof="+strings.Join(service.Scopes, " "))
+
+	var publicReads *ratelimit.Limiter
+	if cfg.PublicReadRate > 0 {
+		publicReads = ratelimit.New(cfg.PublicReadRate, max(cfg.PublicReadBurst, 1), publicReadLimiterSize)
+	}
+
 	return &Handler{
-		services:  services,
-		validator: validator.New(),
+		services:    services,
+		validator:   v,
+		publicReads: publicReads,
 	}
 }
 
@@ -28,21 +61,91 @@ func (h *Handler) Init(api *chi.Mux) {
 	api.Route("/auth", func(r chi.Router) {
 		r.Post("/register", h.signUp)
 		r.Post("/login", h.signIn)
+		r.Post("/login/mfa", h.signInMFA)
+		r.Get("/oidc/{provider}", h.oidcRedirect)
+		r.Get("/oidc/{provider}/callback", h.oidcCallback)
+		r.Post("/refresh", h.refresh)
+		r.Post("/verify-email", h.verifyEmail)
+		r.Post("/verify-email/resend", h.resendVerification)
+		r.Post("/password/forgot", h.forgotPassword)
+		r.Post("/password/reset", h.resetPassword)
+		r.Post("/magic-link", h.sendMagicLink)
+		r.Post("/magic-link/consume", h.consumeMagicLink)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.userIdentity, h.requireSession)
+			r.Post("/logout", h.logout)
+			r.Post("/logout-all", h.logoutAll)
+		})
 	})
 
 	api.Route("/users", func(r chi.Router) {
-		r.Use(h.userIdentity)
-		r.Get("/me", h.getProfile)
-		r.Patch("/me", h.updateProfile)
+		r.Group(func(r chi.Router) {
+			r.Use(h.optionalIdentity, h.rateLimit(h.publicReads))
+			r.Get("/{id}", h.getPublicProfile)
+			r.Get("/by-username/{username}", h.getPublicProfileByUsername)
+			r.Get("/{id}/followers", h.listFollowers)
+			r.Get("/{id}/following", h.listFollowing)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.userIdentity)
+			r.With(h.requireScope(service.ScopeProfileRead)).Get("/me", h.getProfile)
+			r.With(h.requireScope(service.ScopeProfileWrite)).Patch("/me", h.updateProfile)
+			r.With(h.requireScope(service.ScopeFollowsWrite)).Post("/{id}/follow", h.follow)
+			r.With(h.requireScope(service.ScopeFollowsWrite)).Delete("/{id}/follow", h.unfollow)
+			r.With(h.requireScope(service.ScopePostsRead)).Get("/{id}/posts", h.listUserPosts)
+			r.With(h.requireScope(service.ScopeProfileRead)).Get("/me/follow-requests", h.listFollowRequests)
+			r.With(h.requireScope(service.ScopeFollowsWrite)).Post("/me/follow-requests/{id}/accept", h.acceptFollowRequest)
+			r.With(h.requireScope(service.ScopeFollowsWrite)).Post("/me/follow-requests/{id}/reject", h.rejectFollowRequest)
+			r.With(h.requireScope(service.ScopeBlocksWrite)).Post("/{id}/block", h.block)
+			r.With(h.requireScope(service.ScopeBlocksWrite)).Delete("/{id}/block", h.unblock)
+			r.With(h.requireScope(service.ScopeBlocksWrite)).Post("/{id}/mute", h.mute)
+			r.With(h.requireScope(service.ScopeBlocksWrite)).Delete("/{id}/mute", h.unmute)
+			r.With(h.requireScope(service.ScopeProfileRead)).Get("/me/blocks", h.listBlocked)
+			r.With(h.requireScope(service.ScopeProfileRead)).Get("/me/mutes", h.listMuted)
+
+			// Account security is only managed from a signed-in session, never with a personal access token.
+			r.Group(func(r chi.Router) {
+				r.Use(h.requireSession)
+				r.Post("/me/password", h.changePassword)
+				r.Get("/me/sessions", h.listSessions)
+				r.Delete("/me/sessions/{id}", h.deleteSession)
+				r.Post("/me/mfa/totp", h.enrollTOTP)
+				r.Post("/me/mfa/totp/confirm", h.confirmTOTP)
+				r.Delete("/me/mfa/totp", h.disableTOTP)
+				r.Post("/me/tokens", h.createAccessToken)
+				r.Get("/me/tokens", h.listAccessTokens)
+				r.Delete("/me/tokens/{id}", h.revokeAccessToken)
+			})
+		})
+	})
+
+	api.Route("/admin", func(r chi.Router) {
+		r.Use(h.userIdentity, h.requireSession)
+		r.With(h.requirePermission(service.PermissionUsersUnlock)).Post("/users/{id}/unlock", h.unlockUser)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.requirePermission(service.PermissionRolesManage))
+			r.Put("/users/{id}/roles/{role}", h.assignRole)
+			r.Delete("/users/{id}/roles/{role}", h.removeRole)
+		})
 	})
 
 	api.Route("/posts", func(r chi.Router) {
 		r.Use(h.userIdentity)
-		r.Post("/", h.createPost)
-		r.Get("/{id}", h.getPost)
-		r.Patch("/{id}", h.updatePost)
-		r.Delete("/{id}", h.deletePost)
+		r.With(h.requireScope(service.ScopePostsWrite)).Post("/", h.createPost)
+		r.With(h.requireScope(service.ScopePostsRead)).Get("/{id}", h.getPost)
+		r.With(h.requireScope(service.ScopePostsWrite)).Patch("/{id}", h.updatePost)
+		r.With(h.requireScope(service.ScopePostsWrite)).Delete("/{id}", h.deletePost)
+		r.With(h.requireScope(service.ScopePostsWrite)).Post("/{id}/like", h.likePost)
+		r.With(h.requireScope(service.ScopePostsWrite)).Delete("/{id}/like", h.unlikePost)
+		r.With(h.requireScope(service.ScopePostsRead)).Get("/{id}/likes", h.listPostLikes)
+		r.With(h.requireScope(service.ScopePostsWrite)).Post("/{id}/reactions/{emoji}", h.reactToPost)
+		r.With(h.requireScope(service.ScopePostsWrite)).Delete("/{id}/reactions/{emoji}", h.unreactToPost)
 	})
+
+	api.With(h.userIdentity, h.requireScope(service.ScopePostsRead)).Get("/feed", h.getFeed)
 }
 
 // @Summary Register a new user
@@ -80,13 +183,15 @@ func (h *Handler) signUp(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Sign in
-// @Description Authenticate user and return tokens
+// @Description Authenticate user and return tokens. If two-factor authentication is on, the response only
+// @Description carries mfa_required and an mfa_token to complete the sign in at /auth/login/mfa
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param input body service.SignInInput true "Sign in input"
 // @Success 200 {object} service.Tokens
 // @Failure 400 {string} string "Bad Request"
+// @Failure 429 {string} string "Too Many Requests"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /auth/login [post]
 func (h *Handler) signIn(w http.ResponseWriter, r *http.Request) {
@@ -99,8 +204,14 @@ func (h *Handler) signIn(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	input.Device = r.UserAgent()
+	input.IP = clientIP(r)
+
 	tokens, err := h.services.Auth.SignIn(r.Context(), input)
 	if err != nil {
+		if writeThrottled(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -110,6 +221,224 @@ func (h *Handler) signIn(w http.ResponseWriter, r *http.Request) {
 	_ = enc.Encode(tokens)
 }
 
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access/refresh token pair. The refresh token is rotated
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.RefreshInput true "Refresh input"
+// @Success 200 {object} service.Tokens
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/refresh [post]
+func (h *Handler) refresh(w http.ResponseWriter, r *http.Request) {
+	var input service.RefreshInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.Device = r.UserAgent()
+	input.IP = clientIP(r)
+
+	tokens, err := h.services.Auth.Refresh(r.Context(), input)
+	if err != nil {
+		switch err.Error() {
+		case errInvalidRefreshToken, errRefreshTokenExpired, errRefreshTokenReused:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(tokens)
+}
+
+// @Summary Verify email
+// @Description Confirm the email address of an account with the token sent by email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.VerifyEmailInput true "Verify email input"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/verify-email [post]
+func (h *Handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var input service.VerifyEmailInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.VerifyEmail(r.Context(), input); err != nil {
+		if err.Error() == errInvalidVerification {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Resend verification email
+// @Description Send a new verification email. Always accepted, whether or not the address is registered
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.ResendVerificationInput true "Resend verification input"
+// @Success 202 {string} string "Accepted"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/verify-email/resend [post]
+func (h *Handler) resendVerification(w http.ResponseWriter, r *http.Request) {
+	var input service.ResendVerificationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.ResendVerification(r.Context(), input); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary Request a password reset
+// @Description Email a one-time password reset link. Always accepted, whether or not the address is registered
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.ForgotPasswordInput true "Forgot password input"
+// @Success 202 {string} string "Accepted"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/password/forgot [post]
+func (h *Handler) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input service.ForgotPasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.ForgotPassword(r.Context(), input); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary Reset password
+// @Description Set a new password with a reset token. Every session of the user is revoked
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.ResetPasswordInput true "Reset password input"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/password/reset [post]
+func (h *Handler) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var input service.ResetPasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.ResetPassword(r.Context(), input); err != nil {
+		if err.Error() == errInvalidResetToken || strings.HasPrefix(err.Error(), errWeakPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Log out
+// @Description Revoke the current session and its refresh tokens
+// @Tags auth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {string} string "OK"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/logout [post]
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, ok := r.Context().Value(CtxKeySessionID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "session id not found", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.services.Auth.Logout(r.Context(), userID, sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Log out everywhere
+// @Description Revoke every session of the current user and their refresh tokens
+// @Tags auth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {string} string "OK"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/logout-all [post]
+func (h *Handler) logoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.services.Auth.LogoutAll(r.Context(), userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 // @Summary Get user profile
 // @Description Get current user profile information
 // @Tags users
@@ -176,3 +505,116 @@ func (h *Handler) updateProfile(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// @Summary List active sessions
+// @Description List the devices the current user is signed in on
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} entity.Session
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/sessions [get]
+func (h *Handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, _ := r.Context().Value(CtxKeySessionID).(uuid.UUID)
+
+	sessions, err := h.services.Auth.ListSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sessions)
+}
+
+// @Summary Revoke a session
+// @Description Sign the current user out of one of their sessions
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Session ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/sessions/{id} [delete]
+func (h *Handler) deleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.Logout(r.Context(), userID, sessionID); err != nil {
+		if err.Error() == errSessionNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Change password
+// @Description Change the password of the current user. Every other session is revoked
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param input body service.ChangePasswordInput true "Change password input"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/password [post]
+func (h *Handler) changePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, _ := r.Context().Value(CtxKeySessionID).(uuid.UUID)
+
+	var input service.ChangePasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.ChangePassword(r.Context(), userID, sessionID, input); err != nil {
+		switch {
+		case err.Error() == errInvalidPassword:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.HasPrefix(err.Error(), errWeakPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/defskela/SocialNetwork/internal/service"
+)
+
+const errInvalidMagicLink = "invalid magic link"
+
+// @Summary Request a magic link
+// @Description Email a single-use sign in link. Always accepted, whether or not the address is registered
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.MagicLinkInput true "Magic link input"
+// @Success 202 {string} string "Accepted"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/magic-link [post]
+func (h *Handler) sendMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input service.MagicLinkInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.SendMagicLink(r.Context(), input); err != nil {
+		if writeThrottled(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary Sign in with a magic link
+// @Description Exchange the token from a magic link for an access/refresh token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.ConsumeMagicLinkInput true "Consume magic link input"
+// @Success 200 {object} service.Tokens
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/magic-link/consume [post]
+func (h *Handler) consumeMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input service.ConsumeMagicLinkInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.Device = r.UserAgent()
+	input.IP = clientIP(r)
+
+	tokens, err := h.services.Auth.ConsumeMagicLink(r.Context(), input)
+	if err != nil {
+		if err.Error() == errInvalidMagicLink {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(tokens)
+}
@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/service"
+)
+
+const (
+	errMFAAlreadyEnabled = "mfa already enabled"
+	errMFANotEnabled     = "mfa not enabled"
+	errInvalidMFACode    = "invalid mfa code"
+	errInvalidMFAToken   = "invalid mfa token"
+)
+
+// @Summary Complete sign in with a second factor
+// @Description Exchange the mfa_token returned by /auth/login and a code from the authenticator app,
+// @Description or an unused recovery code, for tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body service.SignInMFAInput true "Sign in MFA input"
+// @Success 200 {object} service.Tokens
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/login/mfa [post]
+func (h *Handler) signInMFA(w http.ResponseWriter, r *http.Request) {
+	var input service.SignInMFAInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.Device = r.UserAgent()
+	input.IP = clientIP(r)
+
+	tokens, err := h.services.Auth.SignInMFA(r.Context(), input)
+	if err != nil {
+		if writeThrottled(w, err) {
+			return
+		}
+		switch err.Error() {
+		case errInvalidMFAToken, errInvalidMFACode:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(tokens)
+}
+
+// @Summary Start TOTP enrollment
+// @Description Generate a TOTP secret for the current user. Two-factor authentication is not on until it is confirmed
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} service.TOTPEnrollment
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/mfa/totp [post]
+func (h *Handler) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	enrollment, err := h.services.Auth.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		if err.Error() == errMFAAlreadyEnabled {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(enrollment)
+}
+
+// @Summary Confirm TOTP enrollment
+// @Description Turn on two-factor authentication with a first code from the authenticator app.
+// @Description Returns one-time recovery codes, which are not shown again
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param input body service.TOTPCodeInput true "TOTP code input"
+// @Success 200 {object} service.RecoveryCodes
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/mfa/totp/confirm [post]
+func (h *Handler) confirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	var input service.TOTPCodeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.services.Auth.ConfirmTOTP(r.Context(), userID, input)
+	if err != nil {
+		switch err.Error() {
+		case errInvalidMFACode, errMFANotEnabled:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errMFAAlreadyEnabled:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(codes)
+}
+
+// @Summary Disable TOTP
+// @Description Turn off two-factor authentication with a current code or an unused recovery code
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param input body service.TOTPCodeInput true "TOTP code input"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/mfa/totp [delete]
+func (h *Handler) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	var input service.TOTPCodeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.DisableTOTP(r.Context(), userID, input); err != nil {
+		switch err.Error() {
+		case errMFANotEnabled:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errInvalidMFACode:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -2,24 +2,61 @@ package v1
 
 import (
 	"context"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/service"
+	"github.com/defskela/SocialNetwork/pkg/ratelimit"
 )
 
 type CtxKey string
 
 const (
-	CtxKeyUserID CtxKey = "userID"
+	CtxKeyUserID    CtxKey = "userID"
+	CtxKeySessionID CtxKey = "sessionID"
+	CtxKeyClaims    CtxKey = "claims"
 )
 
 func (h *Handler) userIdentity(next http.Handler) http.Handler {
+	authenticated := h.authenticate(next)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		header := r.Header.Get("Authorization")
-		if header == "" {
+		if r.Header.Get("Authorization") == "" {
 			http.Error(w, "empty auth header", http.StatusUnauthorized)
 			return
 		}
 
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
+// optionalIdentity identifies the caller like userIdentity if the request carries a token,
+// and lets anonymous requests through without a user id in the context.
+func (h *Handler) optionalIdentity(next http.Handler) http.Handler {
+	authenticated := h.authenticate(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the bearer token and puts the caller's claims into the context.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+
 		headerParts := strings.Split(header, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			http.Error(w, "invalid auth header", http.StatusUnauthorized)
@@ -31,13 +68,129 @@ func (h *Handler) userIdentity(next http.Handler) http.Handler {
 			return
 		}
 
-		userID, err := h.services.Auth.ParseToken(headerParts[1])
+		claims, err := h.services.Auth.ParseToken(r.Context(), headerParts[1])
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), CtxKeyUserID, userID)
+		ctx := context.WithValue(r.Context(), CtxKeyUserID, claims.UserID)
+		ctx = context.WithValue(ctx, CtxKeySessionID, claims.SessionID)
+		ctx = context.WithValue(ctx, CtxKeyClaims, claims)
+		ctx = service.WithCaller(ctx, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// requireScope lets personal access tokens through only if they were granted scope.
+// Sessions are not limited by scopes.
+func (h *Handler) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(CtxKeyClaims).(*service.AuthClaim)
+			if !ok {
+				http.Error(w, "claims not found", http.StatusInternalServerError)
+				return
+			}
+
+			if !claims.HasScope(scope) {
+				http.Error(w, "token lacks scope "+scope, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requirePermission lets the request through only if the access token grants permission.
+// The services check permissions again against the database, this only turns callers away early.
+func (h *Handler) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(CtxKeyClaims).(*service.AuthClaim)
+			if !ok {
+				http.Error(w, "claims not found", http.StatusInternalServerError)
+				return
+			}
+
+			if !claims.HasPermission(permission) {
+				http.Error(w, errForbidden, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSession rejects personal access tokens.
+func (h *Handler) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(CtxKeyClaims).(*service.AuthClaim)
+		if !ok {
+			http.Error(w, "claims not found", http.StatusInternalServerError)
+			return
+		}
+
+		if claims.IsPersonalAccessToken() {
+			http.Error(w, "personal access tokens cannot be used here", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit turns callers away with 429 once they run out of requests in limiter. Signed-in callers
+// are limited per user and anonymous ones per address. A nil limiter lets everything through.
+func (h *Handler) rateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + clientIP(r)
+			if userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID); ok {
+				key = "user:" + userID.String()
+			}
+
+			if ok, retryAfter := limiter.Allow(key); !ok {
+				tooManyRequests(w, retryAfter, "too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the address of the caller. The router has already replaced RemoteAddr
+// with the forwarded address when the request came through a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeThrottled answers 429 with a Retry-After header if err says the caller has to wait.
+func writeThrottled(w http.ResponseWriter, err error) bool {
+	var throttled *service.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	tooManyRequests(w, throttled.RetryAfter, err.Error())
+
+	return true
+}
+
+// tooManyRequests answers 429 with a Retry-After header rounded up to whole seconds.
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
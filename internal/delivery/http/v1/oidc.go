@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/defskela/SocialNetwork/internal/service"
+)
+
+const (
+	errOIDCProviderNotFound = "oidc provider not found"
+	errInvalidOIDCState     = "invalid oidc state"
+	errOIDCSignInFailed     = "oidc sign in failed"
+	errOIDCEmailNotVerified = "email not verified by provider"
+	errOIDCEmailConflict    = "email is registered to another account"
+)
+
+// @Summary Sign in with a provider
+// @Description Redirect to the consent page of an external OpenID provider
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 302 {string} string "Found"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/oidc/{provider} [get]
+func (h *Handler) oidcRedirect(w http.ResponseWriter, r *http.Request) {
+	authURL, err := h.services.Auth.OIDCAuthURL(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		if err.Error() == errOIDCProviderNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// @Summary Provider callback
+// @Description Complete a sign in with an external OpenID provider. The provider's account is linked
+// @Description to the user with the same verified email, or a new user is created
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} service.Tokens
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *Handler) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "provider returned "+providerErr, http.StatusUnauthorized)
+		return
+	}
+
+	input := service.OIDCCallbackInput{
+		Provider: chi.URLParam(r, "provider"),
+		Code:     query.Get("code"),
+		State:    query.Get("state"),
+		Device:   r.UserAgent(),
+		IP:       clientIP(r),
+	}
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.services.Auth.SignInOIDC(r.Context(), input)
+	if err != nil {
+		switch err.Error() {
+		case errOIDCProviderNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errInvalidOIDCState:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errOIDCSignInFailed, errOIDCEmailNotVerified:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errOIDCEmailConflict:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(tokens)
+}
@@ -1,8 +1,12 @@
 package v1
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -10,10 +14,17 @@ import (
 	"github.com/defskela/SocialNetwork/internal/service"
 )
 
-const errForbidden = "forbidden"
+const (
+	errForbidden        = "forbidden"
+	errEmailNotVerified = "email not verified"
+	errInvalidTimeRange = "until must be after since"
+	errPostNotFound     = "post not found"
+
+	errReactionNotAllowed = "reaction not allowed"
+)
 
 // @Summary Create a new post
-// @Description Create a new post for the authenticated user
+// @Description Create a new post for the authenticated user, or a reply to a post they can see
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -22,6 +33,8 @@ const errForbidden = "forbidden"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {string} string "Bad Request"
 // @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /posts [post]
 func (h *Handler) createPost(w http.ResponseWriter, r *http.Request) {
@@ -44,7 +57,14 @@ func (h *Handler) createPost(w http.ResponseWriter, r *http.Request) {
 
 	id, err := h.services.Post.Create(r.Context(), userID, input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch err.Error() {
+		case errEmailNotVerified:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errPostNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -57,7 +77,7 @@ func (h *Handler) createPost(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get a post by ID
-// @Description Get a post by its ID
+// @Description Get a post by its ID. Posts by private accounts are only shown to their approved followers
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -70,6 +90,12 @@ func (h *Handler) createPost(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /posts/{id} [get]
 func (h *Handler) getPost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -77,9 +103,9 @@ func (h *Handler) getPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post, err := h.services.Post.GetByID(r.Context(), id)
+	post, err := h.services.Post.GetByID(r.Context(), userID, id)
 	if err != nil {
-		if err.Error() == "post not found" {
+		if err.Error() == errPostNotFound {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
@@ -187,3 +213,274 @@ func (h *Handler) deletePost(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// @Summary List a user's posts
+// @Description List the posts of a user, newest first. Private accounts are not found except by approved followers
+// @Tags posts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Param since query string false "Only posts created at or after this time, RFC 3339"
+// @Param until query string false "Only posts created before this time, RFC 3339"
+// @Param include_replies query bool false "Include the user's replies to posts, false by default"
+// @Success 200 {object} service.PostPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id}/posts [get]
+func (h *Handler) listUserPosts(w http.ResponseWriter, r *http.Request) {
+	viewerID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pageInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var filter service.PostFilter
+	if filter.Since, err = timeParam(r, "since"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Until, err = timeParam(r, "until"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if raw := r.URL.Query().Get("include_replies"); raw != "" {
+		if filter.IncludeReplies, err = strconv.ParseBool(raw); err != nil {
+			http.Error(w, "invalid include_replies", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.services.Post.ListByUser(r.Context(), viewerID, userID, page, filter)
+	if err != nil {
+		switch err.Error() {
+		case errInvalidCursor, errInvalidTimeRange:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errUserNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// @Summary Like a post
+// @Description Like a post. Liking a post twice is not an error
+// @Tags posts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Post ID"
+// @Success 200 {object} service.LikeResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /posts/{id}/like [post]
+func (h *Handler) likePost(w http.ResponseWriter, r *http.Request) {
+	h.changeLike(w, r, h.services.Post.Like)
+}
+
+// @Summary Unlike a post
+// @Description Take back a like. Unliking a post that was not liked is not an error
+// @Tags posts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Post ID"
+// @Success 200 {object} service.LikeResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /posts/{id}/like [delete]
+func (h *Handler) unlikePost(w http.ResponseWriter, r *http.Request) {
+	h.changeLike(w, r, h.services.Post.Unlike)
+}
+
+func (h *Handler) changeLike(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(ctx context.Context, userID, postID uuid.UUID) (*service.LikeResult, error),
+) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := change(r.Context(), userID, postID)
+	if err != nil {
+		if err.Error() == errPostNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// @Summary List the likes of a post
+// @Description List the users who liked a post, newest like first
+// @Tags posts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Post ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 20 by default and at most 100"
+// @Success 200 {object} service.LikePage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /posts/{id}/likes [get]
+func (h *Handler) listPostLikes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pageInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.services.Post.ListLikes(r.Context(), userID, postID, page)
+	if err != nil {
+		switch err.Error() {
+		case errInvalidCursor:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errPostNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// @Summary React to a post
+// @Description React to a post with one of the allowed emoji. Reacting twice with the same emoji is not an error
+// @Tags posts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Post ID"
+// @Param emoji path string true "Emoji, URL encoded"
+// @Success 200 {object} service.ReactionResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /posts/{id}/reactions/{emoji} [post]
+func (h *Handler) reactToPost(w http.ResponseWriter, r *http.Request) {
+	h.changeReaction(w, r, h.services.Post.React)
+}
+
+// @Summary Take back a reaction
+// @Description Take back a reaction to a post. Taking back a reaction that was not there is not an error
+// @Tags posts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Post ID"
+// @Param emoji path string true "Emoji, URL encoded"
+// @Success 200 {object} service.ReactionResult
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /posts/{id}/reactions/{emoji} [delete]
+func (h *Handler) unreactToPost(w http.ResponseWriter, r *http.Request) {
+	h.changeReaction(w, r, h.services.Post.Unreact)
+}
+
+func (h *Handler) changeReaction(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(ctx context.Context, userID, postID uuid.UUID, emoji string) (*service.ReactionResult, error),
+) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := change(r.Context(), userID, postID, chi.URLParam(r, "emoji"))
+	if err != nil {
+		switch err.Error() {
+		case errReactionNotAllowed:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errPostNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// timeParam reads an optional RFC 3339 time from the query.
+func timeParam(r *http.Request, name string) (*time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, errors.New("invalid " + name)
+	}
+
+	return &t, nil
+}
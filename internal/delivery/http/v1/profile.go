@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+)
+
+// @Summary Get a public profile
+// @Description Get the public profile of a user. Works without authentication; the birthday is only shown
+// @Description if the user shares it, or to the user themselves
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} entity.PublicProfile
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Not Found"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/{id} [get]
+func (h *Handler) getPublicProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	viewerID, _ := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+
+	profile, err := h.services.User.GetPublicProfile(r.Context(), viewerID, id)
+	writePublicProfile(w, profile, err)
+}
+
+// @Summary Get a public profile by username
+// @Description Get the public profile of a user by their username. Works without authentication
+// @Tags users
+// @Produce json
+// @Param username path string true "Username"
+// @Success 200 {object} entity.PublicProfile
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Not Found"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/by-username/{username} [get]
+func (h *Handler) getPublicProfileByUsername(w http.ResponseWriter, r *http.Request) {
+	viewerID, _ := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+
+	profile, err := h.services.User.GetPublicProfileByUsername(r.Context(), viewerID, chi.URLParam(r, "username"))
+	writePublicProfile(w, profile, err)
+}
+
+func writePublicProfile(w http.ResponseWriter, profile *entity.PublicProfile, err error) {
+	if err != nil {
+		if err.Error() == errUserNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(profile)
+}
@@ -3,17 +3,20 @@ package v1
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/defskela/SocialNetwork/internal/config"
-	"github.com/defskela/SocialNetwork/internal/repository/postgres"
+	"github.com/defskela/SocialNetwork/internal/entity"
 	"github.com/defskela/SocialNetwork/internal/service"
 	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -51,16 +54,21 @@ func (s *ProfileHandlerSuite) SetupTest() {
 	s.privKeyPath = testPrivKeyPath
 	s.pubKeyPath = testPubKeyPath
 
-	repo := postgres.NewUserRepository(s.pool)
+	repos := testRepository(s.pool)
 
 	var err error
-	s.authService, err = service.NewAuthService(repo, time.Hour, s.privKeyPath, s.pubKeyPath)
+	s.authService, err = service.NewAuthService(repos, mailer.NewMemory(), testAuthConfig(s.privKeyPath, s.pubKeyPath))
 	s.Require().NoError(err)
 
-	s.userService = service.NewUserService(repo)
+	s.userService = service.NewUserService(repos)
 
-	services := &service.Service{Auth: s.authService, User: s.userService}
-	s.handler = NewHandler(services)
+	services := &service.Service{
+		Auth:   s.authService,
+		User:   s.userService,
+		Follow: service.NewFollowService(repos),
+		Block:  service.NewBlockService(repos),
+	}
+	s.handler = NewHandler(services, Config{})
 
 	s.router = chi.NewRouter()
 	s.handler.Init(s.router)
@@ -239,6 +247,456 @@ func (s *ProfileHandlerSuite) TestAuthMiddleware_Errors() {
 	s.Contains(w.Body.String(), "token is malformed")
 }
 
+func (s *ProfileHandlerSuite) TestSessions() {
+	uniqueIdx := strconv.FormatInt(time.Now().UnixNano(), 10)
+	signUpInput := service.SignUpInput{
+		Username: "sessions_" + uniqueIdx,
+		Email:    "sessions_" + uniqueIdx + "@example.com",
+		Password: "password123",
+	}
+
+	_, err := s.authService.SignUp(context.Background(), signUpInput)
+	s.Require().NoError(err)
+
+	signInInput := service.SignInInput{
+		Email:    signUpInput.Email,
+		Password: signUpInput.Password,
+		Device:   "laptop",
+		IP:       "10.0.0.1",
+	}
+	current, err := s.authService.SignIn(context.Background(), signInInput)
+	s.Require().NoError(err)
+
+	signInInput.Device = "phone"
+	other, err := s.authService.SignIn(context.Background(), signInInput)
+	s.Require().NoError(err)
+
+	claims, err := s.authService.ParseToken(context.Background(), other.AccessToken)
+	s.Require().NoError(err)
+
+	req := httptest.NewRequest("GET", "/users/me/sessions", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+current.AccessToken)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	var sessions []entity.Session
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&sessions))
+	s.Len(sessions, 2)
+	for _, session := range sessions {
+		s.Equal("10.0.0.1", session.IP)
+		s.Equal(session.ID != claims.SessionID, session.Current)
+	}
+
+	req = httptest.NewRequest("DELETE", "/users/me/sessions/"+claims.SessionID.String(), http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+current.AccessToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/users/me", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+other.AccessToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/users/me/sessions/"+uuid.NewString(), http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+current.AccessToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/users/me/sessions/not-a-uuid", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+current.AccessToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *ProfileHandlerSuite) TestChangePassword() {
+	uniqueIdx := strconv.FormatInt(time.Now().UnixNano(), 10)
+	signUpInput := service.SignUpInput{
+		Username: "changepw_" + uniqueIdx,
+		Email:    "changepw_" + uniqueIdx + "@example.com",
+		Password: "password123",
+	}
+
+	_, err := s.authService.SignUp(context.Background(), signUpInput)
+	s.Require().NoError(err)
+
+	signInInput := service.SignInInput{Email: signUpInput.Email, Password: signUpInput.Password}
+	current, err := s.authService.SignIn(context.Background(), signInInput)
+	s.Require().NoError(err)
+	other, err := s.authService.SignIn(context.Background(), signInInput)
+	s.Require().NoError(err)
+
+	tests := []struct {
+		name               string
+		inputBody          string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Wrong Current Password",
+			inputBody:          `{"current_password": "wrong", "new_password": "brand-new-secret"}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Too Short",
+			inputBody:          `{"current_password": "password123", "new_password": "short"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Matches Username",
+			inputBody:          `{"current_password": "password123", "new_password": "` + signUpInput.Username + `"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "OK",
+			inputBody:          `{"current_password": "password123", "new_password": "brand-new-secret"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			req := httptest.NewRequest("POST", "/users/me/password", bytes.NewBufferString(tt.inputBody))
+			req.Header.Set("Authorization", "Bearer "+current.AccessToken)
+			w := httptest.NewRecorder()
+
+			s.router.ServeHTTP(w, req)
+
+			s.Equal(tt.expectedStatusCode, w.Code)
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/users/me", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+current.AccessToken)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/users/me", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+other.AccessToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	_, err = s.authService.SignIn(context.Background(), service.SignInInput{
+		Email:    signUpInput.Email,
+		Password: "brand-new-secret",
+	})
+	s.NoError(err)
+}
+
+func (s *ProfileHandlerSuite) TestPersonalAccessTokens() {
+	token, _ := s.createAndLoginUser()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/users/me/tokens",
+		bytes.NewBufferString(`{"name": "ci", "scopes": ["profile:read"]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusCreated, w.Code)
+
+	var created service.CreatedPersonalAccessToken
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&created))
+	s.True(strings.HasPrefix(created.Token, service.PersonalAccessTokenPrefix))
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/users/me/tokens",
+		bytes.NewBufferString(`{"name": "ci", "scopes": ["admin"]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/users/me", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+created.Token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	// The token was not granted profile:write.
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("PATCH", "/users/me", bytes.NewBufferString(`{"bio": "bot"}`))
+	req.Header.Set("Authorization", "Bearer "+created.Token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// Tokens cannot manage tokens or sessions.
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/users/me/tokens", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+created.Token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("DELETE", "/users/me/tokens/"+created.ID.String(), http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/users/me", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+created.Token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("DELETE", "/users/me/tokens/"+uuid.New().String(), http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *ProfileHandlerSuite) TestPublicProfile() {
+	token, id := s.createAndLoginUser()
+	ctx := context.Background()
+
+	_, err := s.pool.Exec(ctx, "INSERT INTO social.posts (user_id, content) VALUES ($1, 'first'), ($1, 'second')", id)
+	s.Require().NoError(err)
+
+	req := httptest.NewRequest("PATCH", "/users/me", bytes.NewBufferString(`{"birthday": "1990-05-17"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	get := func(path, token string) (int, map[string]any) {
+		req := httptest.NewRequest("GET", path, http.NoBody)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		var body map[string]any
+		if w.Code == http.StatusOK {
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		}
+		return w.Code, body
+	}
+
+	code, profile := get("/users/"+id.String(), "")
+	s.Require().Equal(http.StatusOK, code)
+	s.Equal(id.String(), profile["id"])
+	s.Equal(float64(2), profile["posts_count"])
+	s.Equal(float64(0), profile["followers_count"])
+	s.Equal(float64(0), profile["following_count"])
+	s.NotContains(profile, "email")
+	s.NotContains(profile, "birthday", "the birthday is private by default")
+
+	code, profile = get("/users/"+id.String(), token)
+	s.Require().Equal(http.StatusOK, code)
+	s.Contains(profile, "birthday", "users see their own birthday")
+
+	req = httptest.NewRequest("PATCH", "/users/me", bytes.NewBufferString(`{"birthday_visibility": "public"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	code, profile = get("/users/by-username/"+profile["username"].(string), "")
+	s.Require().Equal(http.StatusOK, code)
+	s.Equal(id.String(), profile["id"])
+	s.Contains(profile, "birthday")
+
+	code, _ = get("/users/"+uuid.NewString(), "")
+	s.Equal(http.StatusNotFound, code)
+
+	code, _ = get("/users/by-username/nobody_"+uuid.NewString(), "")
+	s.Equal(http.StatusNotFound, code)
+
+	code, _ = get("/users/not-a-uuid", "")
+	s.Equal(http.StatusBadRequest, code)
+
+	code, _ = get("/users/"+id.String(), "invalid-token")
+	s.Equal(http.StatusUnauthorized, code)
+
+	req = httptest.NewRequest("PATCH", "/users/me", bytes.NewBufferString(`{"birthday_visibility": "friends"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *ProfileHandlerSuite) TestPublicProfile_RateLimit() {
+	_, id := s.createAndLoginUser()
+
+	router := chi.NewRouter()
+	NewHandler(&service.Service{Auth: s.authService, User: s.userService},
+		Config{PublicReadRate: 1, PublicReadBurst: 2}).Init(router)
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/users/"+id.String(), http.NoBody)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	s.Equal(http.StatusOK, get("192.0.2.1:1234").Code)
+	s.Equal(http.StatusOK, get("192.0.2.1:1234").Code)
+
+	w := get("192.0.2.1:1234")
+	s.Equal(http.StatusTooManyRequests, w.Code)
+	s.Equal("1", w.Header().Get("Retry-After"))
+
+	// Other callers are not affected.
+	s.Equal(http.StatusOK, get("192.0.2.2:1234").Code)
+}
+
+func (s *ProfileHandlerSuite) TestFollow() {
+	aliceToken, alice := s.createAndLoginUser()
+	_, bob := s.createAndLoginUser()
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, http.NoBody)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	s.Equal(http.StatusUnauthorized, do("POST", "/users/"+bob.String()+"/follow", "").Code)
+	s.Equal(http.StatusOK, do("POST", "/users/"+bob.String()+"/follow", aliceToken).Code)
+	s.Equal(http.StatusBadRequest, do("POST", "/users/"+alice.String()+"/follow", aliceToken).Code)
+	s.Equal(http.StatusNotFound, do("POST", "/users/"+uuid.NewString()+"/follow", aliceToken).Code)
+
+	w := do("GET", "/users/"+bob.String()+"/followers", "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var page service.FollowPage
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Users, 1)
+	s.Equal(alice, page.Users[0].ID)
+
+	w = do("GET", "/users/"+bob.String(), "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var profile entity.PublicProfile
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&profile))
+	s.Equal(1, profile.FollowersCount)
+
+	w = do("GET", "/users/"+alice.String()+"/following?limit=1", "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), bob.String())
+
+	s.Equal(http.StatusBadRequest, do("GET", "/users/"+bob.String()+"/followers?limit=0", "").Code)
+	s.Equal(http.StatusBadRequest, do("GET", "/users/"+bob.String()+"/followers?cursor=garbage", "").Code)
+	s.Equal(http.StatusNotFound, do("GET", "/users/"+uuid.NewString()+"/followers", "").Code)
+
+	s.Equal(http.StatusOK, do("DELETE", "/users/"+bob.String()+"/follow", aliceToken).Code)
+
+	w = do("GET", "/users/"+bob.String()+"/followers", "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Empty(page.Users)
+
+	token, err := s.authService.CreatePersonalAccessToken(context.Background(), alice,
+		service.CreatePersonalAccessTokenInput{Name: "bot", Scopes: []string{service.ScopeProfileRead}})
+	s.Require().NoError(err)
+	s.Equal(http.StatusForbidden, do("POST", "/users/"+bob.String()+"/follow", token.Token).Code)
+}
+
+func (s *ProfileHandlerSuite) TestFollowRequests() {
+	ownerToken, owner := s.createAndLoginUser()
+	aliceToken, alice := s.createAndLoginUser()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	s.Require().Equal(http.StatusOK, do("PATCH", "/users/me", ownerToken, `{"is_private": true}`).Code)
+
+	w := do("POST", "/users/"+owner.String()+"/follow", aliceToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var result service.FollowResult
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&result))
+	s.Equal(service.FollowStatusRequested, result.Status)
+
+	w = do("GET", "/users/me/follow-requests", ownerToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var page service.FollowPage
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Users, 1)
+	s.Equal(alice, page.Users[0].ID)
+
+	s.Equal(http.StatusBadRequest, do("POST", "/users/me/follow-requests/not-a-uuid/accept", ownerToken, "").Code)
+	s.Equal(http.StatusNotFound, do("POST", "/users/me/follow-requests/"+owner.String()+"/accept", aliceToken, "").Code)
+	s.Equal(http.StatusOK, do("POST", "/users/me/follow-requests/"+alice.String()+"/accept", ownerToken, "").Code)
+	s.Equal(http.StatusNotFound, do("POST", "/users/me/follow-requests/"+alice.String()+"/reject", ownerToken, "").Code)
+
+	w = do("GET", "/users/"+owner.String()+"/followers", "", "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Users, 1)
+	s.Equal(alice, page.Users[0].ID)
+
+	w = do("POST", "/users/"+owner.String()+"/follow", aliceToken, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&result))
+	s.Equal(service.FollowStatusFollowing, result.Status)
+}
+
+func (s *ProfileHandlerSuite) TestBlock() {
+	aliceToken, alice := s.createAndLoginUser()
+	bobToken, bob := s.createAndLoginUser()
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, http.NoBody)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	s.Require().Equal(http.StatusOK, do("POST", "/users/"+alice.String()+"/follow", bobToken).Code)
+
+	s.Equal(http.StatusUnauthorized, do("POST", "/users/"+bob.String()+"/block", "").Code)
+	s.Equal(http.StatusBadRequest, do("POST", "/users/"+alice.String()+"/block", aliceToken).Code)
+	s.Equal(http.StatusNotFound, do("POST", "/users/"+uuid.NewString()+"/block", aliceToken).Code)
+	s.Require().Equal(http.StatusOK, do("POST", "/users/"+bob.String()+"/block", aliceToken).Code)
+
+	s.Equal(http.StatusNotFound, do("GET", "/users/"+alice.String(), bobToken).Code)
+	s.Equal(http.StatusNotFound, do("GET", "/users/"+bob.String(), aliceToken).Code)
+	s.Equal(http.StatusNotFound, do("POST", "/users/"+alice.String()+"/follow", bobToken).Code)
+	s.Equal(http.StatusOK, do("GET", "/users/"+alice.String(), "").Code)
+
+	w := do("GET", "/users/me/blocks", aliceToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var page service.RestrictedPage
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Users, 1)
+	s.Equal(bob, page.Users[0].ID)
+
+	w = do("GET", "/users/"+alice.String()+"/followers", "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var followers service.FollowPage
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&followers))
+	s.Empty(followers.Users)
+
+	s.Require().Equal(http.StatusOK, do("DELETE", "/users/"+bob.String()+"/block", aliceToken).Code)
+	s.Equal(http.StatusOK, do("GET", "/users/"+alice.String(), bobToken).Code)
+
+	s.Require().Equal(http.StatusOK, do("POST", "/users/"+bob.String()+"/mute", aliceToken).Code)
+	s.Equal(http.StatusOK, do("GET", "/users/"+alice.String(), bobToken).Code)
+
+	w = do("GET", "/users/me/mutes", aliceToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&page))
+	s.Require().Len(page.Users, 1)
+	s.Equal(bob, page.Users[0].ID)
+
+	s.Equal(http.StatusOK, do("DELETE", "/users/"+bob.String()+"/mute", aliceToken).Code)
+	s.Equal(http.StatusBadRequest, do("POST", "/users/"+alice.String()+"/mute", aliceToken).Code)
+}
+
 func TestProfileHandlerSuite(t *testing.T) {
 	suite.Run(t, new(ProfileHandlerSuite))
 }
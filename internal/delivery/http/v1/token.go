@@ -0,0 +1,127 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/service"
+)
+
+const (
+	errTokenNotFound = "token not found"
+	errInvalidExpiry = "expiry must be in the future"
+)
+
+// @Summary Create a personal access token
+// @Description Create a token for scripts and bots. The token is only returned this once
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param input body service.CreatePersonalAccessTokenInput true "Create token input"
+// @Success 201 {object} service.CreatedPersonalAccessToken
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/tokens [post]
+func (h *Handler) createAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	var input service.CreatePersonalAccessTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.services.Auth.CreatePersonalAccessToken(r.Context(), userID, input)
+	if err != nil {
+		if err.Error() == errInvalidExpiry {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(token)
+}
+
+// @Summary List personal access tokens
+// @Description List the active personal access tokens of the current user
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} entity.PersonalAccessToken
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/tokens [get]
+func (h *Handler) listAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := h.services.Auth.ListPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tokens)
+}
+
+// @Summary Revoke a personal access token
+// @Description Revoke one of the current user's personal access tokens
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Token ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /users/me/tokens/{id} [delete]
+func (h *Handler) revokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(CtxKeyUserID).(uuid.UUID)
+	if !ok {
+		http.Error(w, "user id not found", http.StatusInternalServerError)
+		return
+	}
+
+	tokenID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid token id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.Auth.RevokePersonalAccessToken(r.Context(), userID, tokenID); err != nil {
+		if err.Error() == errTokenNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
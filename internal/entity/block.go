@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RestrictedUser is an entry in a list of blocked or muted users.
+// Since is when they were blocked or muted.
+type RestrictedUser struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	Username string    `json:"username" db:"username"`
+	Bio      *string   `json:"bio,omitempty" db:"bio"`
+	Since    time.Time `json:"since" db:"created_at"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cursor marks the last item of a page in a list ordered by CreatedAt and ID, both descending.
+// The next page starts right after it.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
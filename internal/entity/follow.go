@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FollowUser is an entry in a list of followers, followings or follow requests.
+// FollowedAt is when the follow was made, or requested.
+type FollowUser struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	Username   string    `json:"username" db:"username"`
+	Bio        *string   `json:"bio,omitempty" db:"bio"`
+	FollowedAt time.Time `json:"followed_at" db:"created_at"`
+}
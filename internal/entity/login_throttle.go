@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// LoginThrottle counts recent failed sign in attempts for an account or a client IP.
+type LoginThrottle struct {
+	Scope         string     `json:"scope" db:"scope"`
+	Key           string     `json:"key" db:"key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" db:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty" db:"blocked_until"`
+}
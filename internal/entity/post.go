@@ -10,6 +10,24 @@ type Post struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	UserID    uuid.UUID `json:"user_id" db:"user_id"`
 	Content   string    `json:"content" db:"content"`
+	LikeCount int       `json:"like_count" db:"like_count"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// ReplyToID is the post this one replies to, which may have been deleted since.
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty" db:"reply_to_id"`
+
+	// LikedByMe tells whether the user who asked for the post has liked it.
+	LikedByMe bool `json:"liked_by_me" db:"-"`
+	// Reactions counts the reactions on the post by emoji, and MyReactions are those of the user who asked.
+	Reactions   map[string]int `json:"reactions" db:"-"`
+	MyReactions []string       `json:"my_reactions" db:"-"`
+}
+
+// Liker is an entry in the list of users who liked a post.
+type Liker struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	Username string    `json:"username" db:"username"`
+	Bio      *string   `json:"bio,omitempty" db:"bio"`
+	LikedAt  time.Time `json:"liked_at" db:"created_at"`
 }
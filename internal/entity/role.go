@@ -0,0 +1,7 @@
+package entity
+
+// Role is a named set of permissions granted to a user.
+type Role struct {
+	Name        string   `json:"name" db:"name"`
+	Permissions []string `json:"permissions" db:"permissions"`
+}
@@ -6,13 +6,38 @@ import (
 	"github.com/google/uuid"
 )
 
+// Who can see the birthday on a user's public profile.
+const (
+	BirthdayVisibilityPublic  = "public"
+	BirthdayVisibilityPrivate = "private"
+)
+
 type User struct {
-	ID           uuid.UUID  `json:"id" db:"id"`
-	Username     string     `json:"username" db:"username"`
-	Email        string     `json:"email" db:"email"`
-	PasswordHash string     `json:"-" db:"password_hash"`
-	Bio          *string    `json:"bio,omitempty" db:"bio"`
-	Birthday     *time.Time `json:"birthday,omitempty" db:"birthday"`
-	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	ID                 uuid.UUID  `json:"id" db:"id"`
+	Username           string     `json:"username" db:"username"`
+	Email              string     `json:"email" db:"email"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	PasswordHash       string     `json:"-" db:"password_hash"`
+	Bio                *string    `json:"bio,omitempty" db:"bio"`
+	Birthday           *time.Time `json:"birthday,omitempty" db:"birthday"`
+	BirthdayVisibility string     `json:"birthday_visibility" db:"birthday_visibility"`
+	// IsPrivate accounts approve their followers, and only followers see their posts.
+	IsPrivate bool      `json:"is_private" db:"is_private"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PublicProfile is what anyone may see of a user: no email, and the birthday only when the user shares it.
+type PublicProfile struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Username       string     `json:"username" db:"username"`
+	Bio            *string    `json:"bio,omitempty" db:"bio"`
+	Birthday       *time.Time `json:"birthday,omitempty" db:"birthday"`
+	FollowersCount int        `json:"followers_count" db:"followers_count"`
+	FollowingCount int        `json:"following_count" db:"following_count"`
+	PostsCount     int        `json:"posts_count" db:"posts_count"`
+	IsPrivate      bool       `json:"is_private" db:"is_private"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+
+	BirthdayVisibility string `json:"-" db:"birthday_visibility"`
 }
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"testing"
 	"time"
@@ -17,7 +18,12 @@ import (
 	"github.com/defskela/SocialNetwork/internal/repository/postgres"
 	"github.com/defskela/SocialNetwork/internal/service"
 	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
+	"github.com/defskela/SocialNetwork/pkg/oidc"
+	"github.com/defskela/SocialNetwork/pkg/oidc/oidctest"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/suite"
 )
@@ -29,8 +35,13 @@ type AuthIntegrationSuite struct {
 	privKeyPath string
 	pubKeyPath  string
 	client      *http.Client
+	repo        *repository.Repository
+	authService service.AuthService
+	provider    *oidctest.Server
 }
 
+const oidcRedirectURL = "http://localhost:8080/api/v1/auth/oidc/test/callback"
+
 func (s *AuthIntegrationSuite) SetupSuite() {
 	cfg := config.MustLoadPath("../../configs/local.yaml")
 	cfg.Postgres.Host = "localhost"
@@ -49,13 +60,66 @@ func (s *AuthIntegrationSuite) TearDownSuite() {
 func (s *AuthIntegrationSuite) SetupTest() {
 	s.privKeyPath = "../../certs/local/private.pem"
 	s.pubKeyPath = "../../certs/local/public.pem"
+	s.provider = oidctest.NewServer("social-network", "secret")
 
 	userRepo := postgres.NewUserRepository(s.pool)
 	postRepo := postgres.NewPostRepository(s.pool)
-	repo := repository.NewRepository(userRepo, postRepo)
+	refreshTokenRepo := postgres.NewRefreshTokenRepository(s.pool)
+	sessionRepo := postgres.NewSessionRepository(s.pool)
+	passwordResetRepo := postgres.NewPasswordResetRepository(s.pool)
+	mfaRepo := postgres.NewMFARepository(s.pool)
+	loginThrottleRepo := postgres.NewLoginThrottleRepository(s.pool)
+	accessTokenRepo := postgres.NewPersonalAccessTokenRepository(s.pool)
+	roleRepo := postgres.NewRoleRepository(s.pool)
+	oidcRepo := postgres.NewOIDCRepository(s.pool)
+	magicLinkRepo := postgres.NewMagicLinkRepository(s.pool)
+	followRepo := postgres.NewFollowRepository(s.pool)
+	blockRepo := postgres.NewBlockRepository(s.pool)
+	timelineRepo := postgres.NewTimelineRepository(s.pool)
+	likeRepo := postgres.NewLikeRepository(s.pool)
+	reactionRepo := postgres.NewReactionRepository(s.pool)
+	repo := repository.NewRepository(
+		userRepo,
+		postRepo,
+		refreshTokenRepo,
+		sessionRepo,
+		passwordResetRepo,
+		mfaRepo,
+		loginThrottleRepo,
+		accessTokenRepo,
+		roleRepo,
+		oidcRepo,
+		magicLinkRepo,
+		followRepo,
+		blockRepo,
+		timelineRepo,
+		likeRepo,
+		reactionRepo,
+	)
 
-	authService, err := service.NewAuthService(repo.User, time.Hour, s.privKeyPath, s.pubKeyPath)
+	authService, err := service.NewAuthService(repo, mailer.NewMemory(), service.AuthConfig{
+		Algorithm:            keyring.RS256,
+		PrivateKeyPath:       s.privKeyPath,
+		PublicKeyPath:        s.pubKeyPath,
+		AccessTokenTTL:       time.Hour,
+		Issuer:               "http://localhost:8080",
+		Audience:             "social-network-api",
+		Leeway:               time.Second,
+		RefreshTokenTTL:      24 * time.Hour,
+		PublicURL:            "http://localhost:8080",
+		EmailVerificationTTL: time.Hour,
+		PasswordResetTTL:     time.Hour,
+		RevocationCacheTTL:   time.Second,
+		PasswordPolicy:       service.PasswordPolicyConfig{MinLength: 8},
+		MFAChallengeTTL:      5 * time.Minute,
+		TOTPIssuer:           "SocialNetwork",
+		MagicLink:            service.MagicLinkConfig{TTL: 5 * time.Minute, MaxRequests: 3, Window: 15 * time.Minute},
+		OIDCProviders:        map[string]oidc.Config{"test": s.provider.Config(oidcRedirectURL)},
+		OIDCStateTTL:         time.Minute,
+	})
 	s.Require().NoError(err)
+	s.repo = repo
+	s.authService = authService
 
 	services := &service.Service{Auth: authService}
 
@@ -66,7 +130,7 @@ func (s *AuthIntegrationSuite) SetupTest() {
 		},
 	}
 
-	handler := httpHandler.NewHandler(services)
+	handler := httpHandler.NewHandler(services, httpHandler.Config{})
 	router := handler.Init()
 
 	s.server = httptest.NewServer(router)
@@ -77,6 +141,9 @@ func (s *AuthIntegrationSuite) TearDownTest() {
 	if s.server != nil {
 		s.server.Close()
 	}
+	if s.provider != nil {
+		s.provider.Close()
+	}
 }
 
 func (s *AuthIntegrationSuite) POST(path string, body interface{}) (statusCode int, responseBody string) {
@@ -126,6 +193,133 @@ func (s *AuthIntegrationSuite) TestRegisterAndLogin() {
 
 	s.Equal(http.StatusOK, statusCode)
 	s.Contains(body, `"access_token":`)
+
+	var tokens service.Tokens
+	s.Require().NoError(json.Unmarshal([]byte(body), &tokens))
+
+	statusCode, body = s.POST("/api/v1/auth/refresh", map[string]string{"refresh_token": tokens.RefreshToken})
+
+	s.Equal(http.StatusOK, statusCode)
+	s.Contains(body, `"refresh_token":`)
+
+	statusCode, _ = s.POST("/api/v1/auth/refresh", map[string]string{"refresh_token": tokens.RefreshToken})
+
+	s.Equal(http.StatusUnauthorized, statusCode)
+}
+
+// oidcCallback walks through the redirects of a sign in with the stand-in provider and returns
+// the callback URL on the API the provider sends the browser back to.
+func (s *AuthIntegrationSuite) oidcCallback() string {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	location := s.server.URL + "/api/v1/auth/oidc/test"
+	for range 2 {
+		req, err := http.NewRequestWithContext(context.Background(), "GET", location, http.NoBody)
+		s.Require().NoError(err)
+
+		resp, err := client.Do(req)
+		s.Require().NoError(err)
+		resp.Body.Close()
+		s.Require().Equal(http.StatusFound, resp.StatusCode)
+
+		location = resp.Header.Get("Location")
+	}
+
+	// The redirect URL registered with the provider points at the configured public address.
+	callback, err := url.Parse(location)
+	s.Require().NoError(err)
+
+	return s.server.URL + callback.RequestURI()
+}
+
+func (s *AuthIntegrationSuite) GET(target string) (statusCode int, responseBody string) {
+	req, err := http.NewRequestWithContext(context.Background(), "GET", target, http.NoBody)
+	s.Require().NoError(err)
+
+	resp, err := s.client.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+
+	return resp.StatusCode, string(respBody)
+}
+
+func (s *AuthIntegrationSuite) oidcUserID(body string) uuid.UUID {
+	var tokens service.Tokens
+	s.Require().NoError(json.Unmarshal([]byte(body), &tokens))
+
+	claims, err := s.authService.ParseToken(context.Background(), tokens.AccessToken)
+	s.Require().NoError(err)
+
+	return claims.UserID
+}
+
+func (s *AuthIntegrationSuite) TestOIDCSignIn() {
+	unique := strconv.FormatInt(time.Now().UnixNano(), 10)
+	s.provider.SetUser(oidctest.User{
+		Subject:       "subject_" + unique,
+		Email:         "oidc_" + unique + "@test.com",
+		EmailVerified: true,
+		Name:          "OIDC User",
+	})
+
+	callback := s.oidcCallback()
+	statusCode, body := s.GET(callback)
+	s.Require().Equal(http.StatusOK, statusCode, body)
+	userID := s.oidcUserID(body)
+
+	user, err := s.repo.User.GetByID(context.Background(), userID)
+	s.Require().NoError(err)
+	s.Equal("oidc_"+unique+"@test.com", user.Email)
+	s.NotNil(user.EmailVerifiedAt)
+
+	// The state is single use.
+	statusCode, _ = s.GET(callback)
+	s.Equal(http.StatusBadRequest, statusCode)
+
+	// The identity now leads to the same user.
+	statusCode, body = s.GET(s.oidcCallback())
+	s.Require().Equal(http.StatusOK, statusCode, body)
+	s.Equal(userID, s.oidcUserID(body))
+}
+
+func (s *AuthIntegrationSuite) TestOIDCSignIn_LinksExistingUser() {
+	unique := strconv.FormatInt(time.Now().UnixNano(), 10)
+	email := "linked_" + unique + "@test.com"
+
+	userID, err := s.authService.SignUp(context.Background(), service.SignUpInput{
+		Username: "linked_" + unique,
+		Email:    email,
+		Password: "password123",
+	})
+	s.Require().NoError(err)
+
+	s.provider.SetUser(oidctest.User{Subject: "linked_" + unique, Email: email, EmailVerified: true})
+
+	// An unverified local address could belong to anyone, so it is not linked.
+	statusCode, _ := s.GET(s.oidcCallback())
+	s.Equal(http.StatusConflict, statusCode)
+
+	s.Require().NoError(s.repo.User.MarkEmailVerified(context.Background(), userID, email))
+
+	statusCode, body := s.GET(s.oidcCallback())
+	s.Require().Equal(http.StatusOK, statusCode, body)
+	s.Equal(userID, s.oidcUserID(body))
+}
+
+func (s *AuthIntegrationSuite) TestOIDCSignIn_UnverifiedEmail() {
+	unique := strconv.FormatInt(time.Now().UnixNano(), 10)
+	s.provider.SetUser(oidctest.User{Subject: "unverified_" + unique, Email: "unverified_" + unique + "@test.com"})
+
+	statusCode, _ := s.GET(s.oidcCallback())
+	s.Equal(http.StatusUnauthorized, statusCode)
+
+	statusCode, _ = s.GET(s.server.URL + "/api/v1/auth/oidc/unknown")
+	s.Equal(http.StatusNotFound, statusCode)
 }
 
 func TestAuthIntegrationSuite(t *testing.T) {
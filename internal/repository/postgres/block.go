@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type blockRepository struct {
+	client postgresql.Client
+}
+
+func NewBlockRepository(client postgresql.Client) repository.BlockRepository {
+	return &blockRepository{
+		client: client,
+	}
+}
+
+// Block records that blockerID blocked blockedID and, in the same transaction, drops the follows and
+// follow requests between them in both directions. Blocking someone twice is not an error.
+func (r *blockRepository) Block(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockPair(ctx, tx, blockerID, blockedID); err != nil {
+		return err
+	}
+
+	q := `
+		INSERT INTO social.blocks (blocker_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`
+
+	if _, err := tx.Exec(ctx, q, blockerID, blockedID); err != nil {
+		return err
+	}
+
+	if _, err := deleteFollow(ctx, tx, blockerID, blockedID); err != nil {
+		return err
+	}
+
+	if _, err := deleteFollow(ctx, tx, blockedID, blockerID); err != nil {
+		return err
+	}
+
+	q = `
+		DELETE FROM social.follow_requests
+		WHERE (requester_id = $1 AND target_id = $2) OR (requester_id = $2 AND target_id = $1)
+	`
+
+	if _, err := tx.Exec(ctx, q, blockerID, blockedID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Unblock removes the block. Unblocking someone who was not blocked is not an error.
+func (r *blockRepository) Unblock(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	q := `
+		DELETE FROM social.blocks
+		WHERE blocker_id = $1 AND blocked_id = $2
+	`
+
+	_, err := r.client.Exec(ctx, q, blockerID, blockedID)
+	return err
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (r *blockRepository) IsBlocked(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	q := `
+		SELECT EXISTS (
+			SELECT 1 FROM social.blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`
+
+	var blocked bool
+	if err := r.client.QueryRow(ctx, q, a, b).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+func (r *blockRepository) ListBlocked(
+	ctx context.Context,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.RestrictedUser, error) {
+	q := `
+		SELECT u.id, u.username, u.bio, b.created_at
+		FROM social.blocks b
+		JOIN social.users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+			AND ($2::timestamptz IS NULL OR (b.created_at, b.blocked_id) < ($2, $3::uuid))
+		ORDER BY b.created_at DESC, b.blocked_id DESC
+		LIMIT $4
+	`
+
+	return r.list(ctx, q, userID, after, limit)
+}
+
+// Mute records that muterID muted mutedID. Muting someone twice is not an error.
+func (r *blockRepository) Mute(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	q := `
+		INSERT INTO social.mutes (muter_id, muted_id)
+		VALUES ($1, $2)
+		ON CONFLICT (muter_id, muted_id) DO NOTHING
+	`
+
+	if _, err := r.client.Exec(ctx, q, muterID, mutedID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("user not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Unmute removes the mute. Unmuting someone who was not muted is not an error.
+func (r *blockRepository) Unmute(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	q := `
+		DELETE FROM social.mutes
+		WHERE muter_id = $1 AND muted_id = $2
+	`
+
+	_, err := r.client.Exec(ctx, q, muterID, mutedID)
+	return err
+}
+
+func (r *blockRepository) ListMuted(
+	ctx context.Context,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.RestrictedUser, error) {
+	q := `
+		SELECT u.id, u.username, u.bio, m.created_at
+		FROM social.mutes m
+		JOIN social.users u ON u.id = m.muted_id
+		WHERE m.muter_id = $1
+			AND ($2::timestamptz IS NULL OR (m.created_at, m.muted_id) < ($2, $3::uuid))
+		ORDER BY m.created_at DESC, m.muted_id DESC
+		LIMIT $4
+	`
+
+	return r.list(ctx, q, userID, after, limit)
+}
+
+func (r *blockRepository) list(
+	ctx context.Context,
+	q string,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.RestrictedUser, error) {
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, &after.ID
+	}
+
+	rows, err := r.client.Query(ctx, q, userID, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := make([]entity.RestrictedUser, 0, limit)
+	for rows.Next() {
+		var user entity.RestrictedUser
+		if err := rows.Scan(&user.ID, &user.Username, &user.Bio, &user.Since); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,402 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type followRepository struct {
+	client postgresql.Client
+}
+
+func NewFollowRepository(client postgresql.Client) repository.FollowRepository {
+	return &followRepository{
+		client: client,
+	}
+}
+
+// Follow records that followerID follows followeeID and reports whether they did not already.
+// The counts on both users change in the same transaction. It fails with "user is blocked" if either
+// has blocked the other.
+func (r *followRepository) Follow(ctx context.Context, followerID, followeeID uuid.UUID) (bool, error) {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockPair(ctx, tx, followerID, followeeID); err != nil {
+		return false, err
+	}
+
+	if err := checkNotBlocked(ctx, tx, followerID, followeeID); err != nil {
+		return false, err
+	}
+
+	created, err := insertFollow(ctx, tx, followerID, followeeID)
+	if err != nil {
+		return false, err
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+// Unfollow removes the follow and reports whether there was one.
+func (r *followRepository) Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) (bool, error) {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockPair(ctx, tx, followerID, followeeID); err != nil {
+		return false, err
+	}
+
+	deleted, err := deleteFollow(ctx, tx, followerID, followeeID)
+	if err != nil {
+		return false, err
+	}
+
+	return deleted, tx.Commit(ctx)
+}
+
+func (r *followRepository) IsFollowing(ctx context.Context, followerID, followeeID uuid.UUID) (bool, error) {
+	q := `
+		SELECT EXISTS (
+			SELECT 1 FROM social.follows
+			WHERE follower_id = $1 AND followee_id = $2
+		)
+	`
+
+	var following bool
+	if err := r.client.QueryRow(ctx, q, followerID, followeeID).Scan(&following); err != nil {
+		return false, err
+	}
+
+	return following, nil
+}
+
+// Request asks to follow a private account. Asking again is not an error. It fails with "user is blocked"
+// if either has blocked the other.
+func (r *followRepository) Request(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockPair(ctx, tx, requesterID, targetID); err != nil {
+		return err
+	}
+
+	if err := checkNotBlocked(ctx, tx, requesterID, targetID); err != nil {
+		return err
+	}
+
+	q := `
+		INSERT INTO social.follow_requests (requester_id, target_id)
+		VALUES ($1, $2)
+		ON CONFLICT (requester_id, target_id) DO NOTHING
+	`
+
+	if _, err := tx.Exec(ctx, q, requesterID, targetID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *followRepository) DeleteRequest(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	q := `
+		DELETE FROM social.follow_requests
+		WHERE requester_id = $1 AND target_id = $2
+	`
+
+	tag, err := r.client.Exec(ctx, q, requesterID, targetID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("follow request not found")
+	}
+
+	return nil
+}
+
+// AcceptRequest turns a follow request into a follow. It fails with "user is blocked" if either
+// has blocked the other.
+func (r *followRepository) AcceptRequest(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockPair(ctx, tx, requesterID, targetID); err != nil {
+		if err.Error() == "user not found" {
+			return fmt.Errorf("follow request not found")
+		}
+		return err
+	}
+
+	if err := checkNotBlocked(ctx, tx, requesterID, targetID); err != nil {
+		return err
+	}
+
+	q := `
+		DELETE FROM social.follow_requests
+		WHERE requester_id = $1 AND target_id = $2
+	`
+
+	tag, err := tx.Exec(ctx, q, requesterID, targetID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("follow request not found")
+	}
+
+	if _, err := insertFollow(ctx, tx, requesterID, targetID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *followRepository) ListRequests(
+	ctx context.Context,
+	targetID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.FollowUser, error) {
+	q := `
+		SELECT u.id, u.username, u.bio, fr.created_at
+		FROM social.follow_requests fr
+		JOIN social.users u ON u.id = fr.requester_id
+		WHERE fr.target_id = $1
+			AND ($2::timestamptz IS NULL OR (fr.created_at, fr.requester_id) < ($2, $3::uuid))
+		ORDER BY fr.created_at DESC, fr.requester_id DESC
+		LIMIT $4
+	`
+
+	return r.list(ctx, q, targetID, after, limit)
+}
+
+// lockPair locks both users in the order of their ids before a follow between them changes, so that
+// A following B while B follows A cannot deadlock, and their counts are changed by one transaction at a time.
+func lockPair(ctx context.Context, tx pgx.Tx, a, b uuid.UUID) error {
+	q := `
+		SELECT id FROM social.users
+		WHERE id IN ($1, $2)
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, q, a, b)
+	if err != nil {
+		return err
+	}
+	locked := 0
+	for rows.Next() {
+		locked++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if locked < 2 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// checkNotBlocked fails with "user is blocked" if either user has blocked the other. Called after lockPair,
+// it cannot miss a block that is being made, since Block locks the same users.
+func checkNotBlocked(ctx context.Context, tx pgx.Tx, a, b uuid.UUID) error {
+	q := `
+		SELECT EXISTS (
+			SELECT 1 FROM social.blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`
+
+	var blocked bool
+	if err := tx.QueryRow(ctx, q, a, b).Scan(&blocked); err != nil {
+		return err
+	}
+
+	if blocked {
+		return fmt.Errorf("user is blocked")
+	}
+
+	return nil
+}
+
+// timelineBackfill is how many of their latest posts a newly followed user adds to the follower's timeline.
+const timelineBackfill = 50
+
+// insertFollow adds the follow and counts it, unless it exists already. Both users must be locked.
+func insertFollow(ctx context.Context, tx pgx.Tx, followerID, followeeID uuid.UUID) (bool, error) {
+	q := `
+		INSERT INTO social.follows (follower_id, followee_id)
+		VALUES ($1, $2)
+		ON CONFLICT (follower_id, followee_id) DO NOTHING
+	`
+
+	tag, err := tx.Exec(ctx, q, followerID, followeeID)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := updateCounts(ctx, tx, followerID, followeeID, 1); err != nil {
+		return false, err
+	}
+
+	// Posts that are still waiting are fanned out to the new follower by the worker,
+	// and skipped posts are read when the feed loads.
+	q = `
+		INSERT INTO social.timelines (user_id, post_id, author_id, created_at)
+		SELECT $1, id, user_id, created_at
+		FROM social.posts
+		WHERE user_id = $2 AND fanned_out_at IS NOT NULL AND NOT fan_out_skipped
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+		ON CONFLICT (user_id, post_id) DO NOTHING
+	`
+
+	if _, err := tx.Exec(ctx, q, followerID, followeeID, timelineBackfill); err != nil {
+		return false, fmt.Errorf("failed to backfill timeline: %w", err)
+	}
+
+	return true, nil
+}
+
+// deleteFollow removes the follow and uncounts it, if there is one. Both users must be locked.
+func deleteFollow(ctx context.Context, tx pgx.Tx, followerID, followeeID uuid.UUID) (bool, error) {
+	q := `
+		DELETE FROM social.follows
+		WHERE follower_id = $1 AND followee_id = $2
+	`
+
+	tag, err := tx.Exec(ctx, q, followerID, followeeID)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	return true, updateCounts(ctx, tx, followerID, followeeID, -1)
+}
+
+func updateCounts(ctx context.Context, tx pgx.Tx, followerID, followeeID uuid.UUID, delta int) error {
+	q := `
+		UPDATE social.users
+		SET following_count = following_count + CASE WHEN id = $1 THEN $3 ELSE 0 END,
+			followers_count = followers_count + CASE WHEN id = $2 THEN $3 ELSE 0 END
+		WHERE id IN ($1, $2)
+	`
+
+	if _, err := tx.Exec(ctx, q, followerID, followeeID, delta); err != nil {
+		return fmt.Errorf("failed to update follow counts: %w", err)
+	}
+
+	return nil
+}
+
+func (r *followRepository) ListFollowers(
+	ctx context.Context,
+	viewerID, userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.FollowUser, error) {
+	q := `
+		SELECT u.id, u.username, u.bio, f.created_at
+		FROM social.follows f
+		JOIN social.users u ON u.id = f.follower_id
+		WHERE f.followee_id = $1
+			AND ($2::timestamptz IS NULL OR (f.created_at, f.follower_id) < ($2, $3::uuid))
+			AND NOT EXISTS (
+				SELECT 1 FROM social.blocks b
+				WHERE (b.blocker_id = $5 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $5)
+			)
+		ORDER BY f.created_at DESC, f.follower_id DESC
+		LIMIT $4
+	`
+
+	return r.list(ctx, q, userID, after, limit, viewerID)
+}
+
+func (r *followRepository) ListFollowing(
+	ctx context.Context,
+	viewerID, userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.FollowUser, error) {
+	q := `
+		SELECT u.id, u.username, u.bio, f.created_at
+		FROM social.follows f
+		JOIN social.users u ON u.id = f.followee_id
+		WHERE f.follower_id = $1
+			AND ($2::timestamptz IS NULL OR (f.created_at, f.followee_id) < ($2, $3::uuid))
+			AND NOT EXISTS (
+				SELECT 1 FROM social.blocks b
+				WHERE (b.blocker_id = $5 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $5)
+			)
+		ORDER BY f.created_at DESC, f.followee_id DESC
+		LIMIT $4
+	`
+
+	return r.list(ctx, q, userID, after, limit, viewerID)
+}
+
+func (r *followRepository) list(
+	ctx context.Context,
+	q string,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+	args ...any,
+) ([]entity.FollowUser, error) {
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, &after.ID
+	}
+
+	rows, err := r.client.Query(ctx, q, append([]any{userID, afterTime, afterID, limit}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := make([]entity.FollowUser, 0, limit)
+	for rows.Next() {
+		var user entity.FollowUser
+		if err := rows.Scan(&user.ID, &user.Username, &user.Bio, &user.FollowedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type likeRepository struct {
+	client postgresql.Client
+}
+
+func NewLikeRepository(client postgresql.Client) repository.LikeRepository {
+	return &likeRepository{
+		client: client,
+	}
+}
+
+// Like records that userID likes postID and returns how many likes the post has. Liking a post twice
+// is not an error. The like and the count change in one statement, so concurrent likes cannot lose counts.
+func (r *likeRepository) Like(ctx context.Context, postID, userID uuid.UUID) (int, error) {
+	q := `
+		WITH liked AS (
+			INSERT INTO social.post_likes (post_id, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT (post_id, user_id) DO NOTHING
+			RETURNING post_id
+		), counted AS (
+			UPDATE social.posts
+			SET like_count = like_count + 1
+			WHERE id IN (SELECT post_id FROM liked)
+			RETURNING like_count
+		)
+		SELECT COALESCE(
+			(SELECT like_count FROM counted),
+			(SELECT like_count FROM social.posts WHERE id = $1)
+		)
+	`
+
+	var count *int
+	if err := r.client.QueryRow(ctx, q, postID, userID).Scan(&count); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return 0, fmt.Errorf("post not found")
+		}
+		return 0, err
+	}
+
+	if count == nil {
+		return 0, fmt.Errorf("post not found")
+	}
+
+	return *count, nil
+}
+
+// Unlike removes the like and returns how many likes the post has left. Unliking a post that was
+// not liked is not an error.
+func (r *likeRepository) Unlike(ctx context.Context, postID, userID uuid.UUID) (int, error) {
+	q := `
+		WITH unliked AS (
+			DELETE FROM social.post_likes
+			WHERE post_id = $1 AND user_id = $2
+			RETURNING post_id
+		), counted AS (
+			UPDATE social.posts
+			SET like_count = like_count - 1
+			WHERE id IN (SELECT post_id FROM unliked)
+			RETURNING like_count
+		)
+		SELECT COALESCE(
+			(SELECT like_count FROM counted),
+			(SELECT like_count FROM social.posts WHERE id = $1)
+		)
+	`
+
+	var count *int
+	if err := r.client.QueryRow(ctx, q, postID, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	if count == nil {
+		return 0, fmt.Errorf("post not found")
+	}
+
+	return *count, nil
+}
+
+// LikedBy returns which of postIDs userID has liked.
+func (r *likeRepository) LikedBy(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID) ([]uuid.UUID, error) {
+	q := `
+		SELECT post_id
+		FROM social.post_likes
+		WHERE user_id = $1 AND post_id = ANY($2)
+	`
+
+	rows, err := r.client.Query(ctx, q, userID, postIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var liked []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		liked = append(liked, id)
+	}
+
+	return liked, rows.Err()
+}
+
+func (r *likeRepository) ListLikers(
+	ctx context.Context,
+	viewerID, postID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.Liker, error) {
+	q := `
+		SELECT u.id, u.username, u.bio, l.created_at
+		FROM social.post_likes l
+		JOIN social.users u ON u.id = l.user_id
+		WHERE l.post_id = $1
+			AND ($2::timestamptz IS NULL OR (l.created_at, l.user_id) < ($2, $3::uuid))
+			AND NOT EXISTS (
+				SELECT 1 FROM social.blocks b
+				WHERE (b.blocker_id = $5 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $5)
+			)
+		ORDER BY l.created_at DESC, l.user_id DESC
+		LIMIT $4
+	`
+
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, &after.ID
+	}
+
+	rows, err := r.client.Query(ctx, q, postID, afterTime, afterID, limit, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	likers := make([]entity.Liker, 0, limit)
+	for rows.Next() {
+		var liker entity.Liker
+		if err := rows.Scan(&liker.ID, &liker.Username, &liker.Bio, &liker.LikedAt); err != nil {
+			return nil, err
+		}
+		likers = append(likers, liker)
+	}
+
+	return likers, rows.Err()
+}
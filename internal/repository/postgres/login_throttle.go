@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type loginThrottleRepository struct {
+	client postgresql.Client
+}
+
+func NewLoginThrottleRepository(client postgresql.Client) repository.LoginThrottleRepository {
+	return &loginThrottleRepository{
+		client: client,
+	}
+}
+
+func (r *loginThrottleRepository) Get(ctx context.Context, scope, key string) (*entity.LoginThrottle, error) {
+	q := `
+		SELECT scope, key, failures, last_failure_at, blocked_until
+		FROM social.login_throttles
+		WHERE scope = $1 AND key = $2
+	`
+
+	var throttle entity.LoginThrottle
+	err := r.client.QueryRow(ctx, q, scope, key).Scan(
+		&throttle.Scope,
+		&throttle.Key,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&throttle.BlockedUntil,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("login throttle not found")
+		}
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// RecordFailure counts a failed attempt at time at and returns the number of failures so far.
+// The count starts over when the previous failure happened before resetBefore.
+// The increment is a single statement, so concurrent attempts from several replicas are all counted.
+func (r *loginThrottleRepository) RecordFailure(
+	ctx context.Context,
+	scope, key string,
+	at, resetBefore time.Time,
+) (int, error) {
+	q := `
+		INSERT INTO social.login_throttles (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET failures = CASE
+				WHEN social.login_throttles.last_failure_at < $4 THEN 1
+				ELSE social.login_throttles.failures + 1
+			END,
+			last_failure_at = $3
+		RETURNING failures
+	`
+
+	var failures int
+	if err := r.client.QueryRow(ctx, q, scope, key, at, resetBefore).Scan(&failures); err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+// Block refuses attempts until the given time. An existing block that lasts longer is kept.
+func (r *loginThrottleRepository) Block(ctx context.Context, scope, key string, until time.Time) error {
+	q := `
+		UPDATE social.login_throttles
+		SET blocked_until = GREATEST(COALESCE(blocked_until, $3), $3)
+		WHERE scope = $1 AND key = $2
+	`
+
+	_, err := r.client.Exec(ctx, q, scope, key, until)
+	return err
+}
+
+func (r *loginThrottleRepository) Reset(ctx context.Context, scope, key string) error {
+	q := `
+		DELETE FROM social.login_throttles
+		WHERE scope = $1 AND key = $2
+	`
+
+	_, err := r.client.Exec(ctx, q, scope, key)
+	return err
+}
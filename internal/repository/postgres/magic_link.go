@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type magicLinkRepository struct {
+	client postgresql.Client
+}
+
+func NewMagicLinkRepository(client postgresql.Client) repository.MagicLinkRepository {
+	return &magicLinkRepository{
+		client: client,
+	}
+}
+
+// Create stores a new link and invalidates every link the user still had outstanding.
+func (r *magicLinkRepository) Create(ctx context.Context, link *entity.MagicLink) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		UPDATE social.magic_links
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	if _, err := tx.Exec(ctx, q, link.UserID); err != nil {
+		return err
+	}
+
+	q = `
+		INSERT INTO social.magic_links (id, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING created_at
+	`
+
+	if err := tx.QueryRow(ctx, q, link.ID, link.UserID, link.ExpiresAt).Scan(&link.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Consume marks an unused, unexpired link as used and returns it.
+// Concurrent attempts to use the same link cannot both succeed.
+func (r *magicLinkRepository) Consume(ctx context.Context, id uuid.UUID) (*entity.MagicLink, error) {
+	q := `
+		UPDATE social.magic_links
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, user_id, expires_at, used_at, created_at
+	`
+
+	var link entity.MagicLink
+	err := r.client.QueryRow(ctx, q, id).Scan(
+		&link.ID,
+		&link.UserID,
+		&link.ExpiresAt,
+		&link.UsedAt,
+		&link.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("magic link not found")
+		}
+		return nil, err
+	}
+
+	return &link, nil
+}
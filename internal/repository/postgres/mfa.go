@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type mfaRepository struct {
+	client postgresql.Client
+}
+
+func NewMFARepository(client postgresql.Client) repository.MFARepository {
+	return &mfaRepository{
+		client: client,
+	}
+}
+
+// SaveTOTP stores a pending enrollment, replacing any earlier one that was never confirmed.
+func (r *mfaRepository) SaveTOTP(ctx context.Context, totp *entity.TOTP) error {
+	q := `
+		INSERT INTO social.user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE social.user_totp.confirmed_at IS NULL
+		RETURNING created_at
+	`
+
+	if err := r.client.QueryRow(ctx, q, totp.UserID, totp.Secret).Scan(&totp.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("totp already confirmed")
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*entity.TOTP, error) {
+	q := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM social.user_totp
+		WHERE user_id = $1
+	`
+
+	var totp entity.TOTP
+	err := r.client.QueryRow(ctx, q, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.ConfirmedAt,
+		&totp.LastUsedStep,
+		&totp.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("totp not found")
+		}
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+// ConfirmTOTP enables a pending enrollment and replaces the user's recovery codes.
+// The step of the confirming code is recorded so that it cannot be used again to sign in.
+func (r *mfaRepository) ConfirmTOTP(
+	ctx context.Context,
+	userID uuid.UUID,
+	step int64,
+	recoveryCodeHashes []string,
+) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		UPDATE social.user_totp
+		SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL
+	`
+
+	tag, err := tx.Exec(ctx, q, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("totp not found")
+	}
+
+	q = `
+		DELETE FROM social.mfa_recovery_codes
+		WHERE user_id = $1
+	`
+
+	if _, err := tx.Exec(ctx, q, userID); err != nil {
+		return err
+	}
+
+	q = `
+		INSERT INTO social.mfa_recovery_codes (user_id, code_hash)
+		SELECT $1, unnest($2::text[])
+	`
+
+	if _, err := tx.Exec(ctx, q, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTOTPStep records that a code for step was accepted. Steps only move forward,
+// so a code that was already used, or an older one, is rejected.
+func (r *mfaRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	q := `
+		UPDATE social.user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+	`
+
+	tag, err := r.client.Exec(ctx, q, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("totp code already used")
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	q := `
+		UPDATE social.mfa_recovery_codes
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	tag, err := r.client.Exec(ctx, q, userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("recovery code not found")
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		DELETE FROM social.mfa_recovery_codes
+		WHERE user_id = $1
+	`
+
+	if _, err := tx.Exec(ctx, q, userID); err != nil {
+		return err
+	}
+
+	q = `
+		DELETE FROM social.user_totp
+		WHERE user_id = $1
+	`
+
+	if _, err := tx.Exec(ctx, q, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
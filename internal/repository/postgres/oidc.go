@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type oidcRepository struct {
+	client postgresql.Client
+}
+
+func NewOIDCRepository(client postgresql.Client) repository.OIDCRepository {
+	return &oidcRepository{
+		client: client,
+	}
+}
+
+func (r *oidcRepository) SaveState(ctx context.Context, state *entity.OIDCState) error {
+	q := `
+		INSERT INTO social.oidc_states (state_hash, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	return r.client.QueryRow(ctx, q, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt).
+		Scan(&state.CreatedAt)
+}
+
+// ConsumeState deletes the state and returns it, so that every state is used at most once.
+// Expired states of any sign in are cleaned up along the way.
+func (r *oidcRepository) ConsumeState(ctx context.Context, stateHash string) (*entity.OIDCState, error) {
+	q := `
+		DELETE FROM social.oidc_states
+		WHERE state_hash = $1 OR expires_at < CURRENT_TIMESTAMP
+		RETURNING state_hash, provider, nonce, code_verifier, expires_at, created_at
+	`
+
+	rows, err := r.client.Query(ctx, q, stateHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found *entity.OIDCState
+	for rows.Next() {
+		var state entity.OIDCState
+		if err := rows.Scan(
+			&state.StateHash,
+			&state.Provider,
+			&state.Nonce,
+			&state.CodeVerifier,
+			&state.ExpiresAt,
+			&state.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if state.StateHash == stateHash {
+			found = &state
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("oidc state not found")
+	}
+
+	return found, nil
+}
+
+func (r *oidcRepository) GetIdentity(ctx context.Context, provider, subject string) (*entity.UserIdentity, error) {
+	q := `
+		SELECT provider, subject, user_id, email, created_at
+		FROM social.user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	var identity entity.UserIdentity
+	err := r.client.QueryRow(ctx, q, provider, subject).Scan(
+		&identity.Provider,
+		&identity.Subject,
+		&identity.UserID,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("identity not found")
+		}
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *oidcRepository) LinkIdentity(ctx context.Context, identity *entity.UserIdentity) error {
+	return linkIdentity(ctx, r.client, identity)
+}
+
+// CreateUserWithIdentity creates a user whose email the provider has verified, together with the link,
+// so that a failed link does not leave behind a user nobody can sign in as.
+func (r *oidcRepository) CreateUserWithIdentity(
+	ctx context.Context,
+	user *entity.User,
+	identity *entity.UserIdentity,
+) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		INSERT INTO social.users (username, email, password_hash, email_verified_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, birthday_visibility, created_at, updated_at
+	`
+
+	if err := tx.QueryRow(ctx, q, user.Username, user.Email, user.PasswordHash, user.EmailVerifiedAt).
+		Scan(&user.ID, &user.BirthdayVisibility, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("user already exists")
+		}
+		return err
+	}
+
+	identity.UserID = user.ID
+	if err := linkIdentity(ctx, tx, identity); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func linkIdentity(ctx context.Context, db queryRower, identity *entity.UserIdentity) error {
+	q := `
+		INSERT INTO social.user_identities (provider, subject, user_id, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+
+	if err := db.QueryRow(ctx, q, identity.Provider, identity.Subject, identity.UserID, identity.Email).
+		Scan(&identity.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("identity already linked")
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type passwordResetRepository struct {
+	client postgresql.Client
+}
+
+func NewPasswordResetRepository(client postgresql.Client) repository.PasswordResetRepository {
+	return &passwordResetRepository{
+		client: client,
+	}
+}
+
+// Create stores a new reset token and invalidates every token the user still had outstanding.
+func (r *passwordResetRepository) Create(ctx context.Context, token *entity.PasswordResetToken) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		UPDATE social.password_reset_tokens
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	if _, err := tx.Exec(ctx, q, token.UserID); err != nil {
+		return err
+	}
+
+	q = `
+		INSERT INTO social.password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	if err := tx.QueryRow(ctx, q, token.UserID, token.TokenHash, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetActive returns an unused, unexpired token without using it up.
+func (r *passwordResetRepository) GetActive(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error) {
+	q := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM social.password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	`
+
+	var token entity.PasswordResetToken
+	err := r.client.QueryRow(ctx, q, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("reset token not found")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token as used and returns it.
+// Concurrent attempts to use the same token cannot both succeed.
+func (r *passwordResetRepository) Consume(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error) {
+	q := `
+		UPDATE social.password_reset_tokens
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, user_id, token_hash, expires_at, used_at, created_at
+	`
+
+	var token entity.PasswordResetToken
+	err := r.client.QueryRow(ctx, q, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("reset token not found")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type personalAccessTokenRepository struct {
+	client postgresql.Client
+}
+
+func NewPersonalAccessTokenRepository(client postgresql.Client) repository.PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{
+		client: client,
+	}
+}
+
+func (r *personalAccessTokenRepository) Create(ctx context.Context, token *entity.PersonalAccessToken) error {
+	q := `
+		INSERT INTO social.personal_access_tokens (user_id, name, token_hash, token_hint, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	if err := r.client.QueryRow(ctx, q,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.Hint,
+		token.Scopes,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *personalAccessTokenRepository) GetByHash(
+	ctx context.Context,
+	tokenHash string,
+) (*entity.PersonalAccessToken, error) {
+	q := `
+		SELECT id, user_id, name, token_hash, token_hint, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM social.personal_access_tokens
+		WHERE token_hash = $1
+	`
+
+	token, err := scanPersonalAccessToken(r.client.QueryRow(ctx, q, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("token not found")
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ListByUser returns the tokens of a user that are neither revoked nor expired, newest first.
+func (r *personalAccessTokenRepository) ListByUser(
+	ctx context.Context,
+	userID uuid.UUID,
+) ([]entity.PersonalAccessToken, error) {
+	q := `
+		SELECT id, user_id, name, token_hash, token_hint, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM social.personal_access_tokens
+		WHERE user_id = $1
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.client.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]entity.PersonalAccessToken, 0)
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Touch records that a token was used. Callers only touch tokens that have not been used for a while,
+// and it still writes at most once a minute per token when concurrent requests all decide to touch.
+func (r *personalAccessTokenRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	q := `
+		UPDATE social.personal_access_tokens
+		SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`
+
+	_, err := r.client.Exec(ctx, q, id)
+	return err
+}
+
+func (r *personalAccessTokenRepository) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	q := `
+		UPDATE social.personal_access_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	tag, err := r.client.Exec(ctx, q, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("token not found")
+	}
+
+	return nil
+}
+
+func scanPersonalAccessToken(row pgx.Row) (*entity.PersonalAccessToken, error) {
+	var token entity.PersonalAccessToken
+	if err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.Hint,
+		&token.Scopes,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -25,12 +26,12 @@ func NewPostRepository(client postgresql.Client) repository.PostRepository {
 
 func (r *postRepository) Create(ctx context.Context, post *entity.Post) error {
 	q := `
-		INSERT INTO social.posts (user_id, content)
-		VALUES ($1, $2)
+		INSERT INTO social.posts (user_id, content, reply_to_id)
+		VALUES ($1, $2, $3)
 		RETURNING id, created_at, updated_at
 	`
 
-	if err := r.client.QueryRow(ctx, q, post.UserID, post.Content).
+	if err := r.client.QueryRow(ctx, q, post.UserID, post.Content, post.ReplyToID).
 		Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt); err != nil {
 		return err
 	}
@@ -40,7 +41,7 @@ func (r *postRepository) Create(ctx context.Context, post *entity.Post) error {
 
 func (r *postRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Post, error) {
 	q := `
-		SELECT id, user_id, content, created_at, updated_at
+		SELECT id, user_id, content, reply_to_id, like_count, created_at, updated_at
 		FROM social.posts
 		WHERE id = $1
 	`
@@ -50,6 +51,8 @@ func (r *postRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Pos
 		&post.ID,
 		&post.UserID,
 		&post.Content,
+		&post.ReplyToID,
+		&post.LikeCount,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 	)
@@ -98,3 +101,104 @@ func (r *postRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+// ListFeed takes at most limit posts from each followed user off idx_posts_user_created and merges them,
+// so a page costs the same however many posts there are.
+func (r *postRepository) ListFeed(
+	ctx context.Context,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.Post, error) {
+	q := `
+		SELECT p.id, p.user_id, p.content, p.reply_to_id, p.like_count, p.created_at, p.updated_at
+		FROM social.follows f
+		CROSS JOIN LATERAL (
+			SELECT id, user_id, content, reply_to_id, like_count, created_at, updated_at
+			FROM social.posts
+			WHERE user_id = f.followee_id
+				AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3::uuid))
+			ORDER BY created_at DESC, id DESC
+			LIMIT $4
+		) p
+		WHERE f.follower_id = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM social.mutes m
+				WHERE m.muter_id = $1 AND m.muted_id = f.followee_id
+			)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $4
+	`
+
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, &after.ID
+	}
+
+	rows, err := r.client.Query(ctx, q, userID, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectPosts(rows, limit)
+}
+
+func (r *postRepository) ListByUser(
+	ctx context.Context,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	since, until *time.Time,
+	includeReplies bool,
+	limit int,
+) ([]entity.Post, error) {
+	q := `
+		SELECT id, user_id, content, reply_to_id, like_count, created_at, updated_at
+		FROM social.posts
+		WHERE user_id = $1
+			AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3::uuid))
+			AND ($5::timestamptz IS NULL OR created_at >= $5)
+			AND ($6::timestamptz IS NULL OR created_at < $6)
+			AND ($7 OR reply_to_id IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`
+
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, &after.ID
+	}
+
+	rows, err := r.client.Query(ctx, q, userID, afterTime, afterID, limit, since, until, includeReplies)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectPosts(rows, limit)
+}
+
+// collectPosts reads and closes rows of id, user_id, content, reply_to_id, like_count, created_at and updated_at.
+func collectPosts(rows pgx.Rows, limit int) ([]entity.Post, error) {
+	defer rows.Close()
+
+	posts := make([]entity.Post, 0, limit)
+	for rows.Next() {
+		var post entity.Post
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Content,
+			&post.ReplyToID,
+			&post.LikeCount,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
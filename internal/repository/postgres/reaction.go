@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type reactionRepository struct {
+	client postgresql.Client
+}
+
+func NewReactionRepository(client postgresql.Client) repository.ReactionRepository {
+	return &reactionRepository{
+		client: client,
+	}
+}
+
+// Add records that userID reacted to postID with emoji. Reacting twice with the same emoji is not an error.
+// The reaction and its count change in one statement, so concurrent reactions cannot lose counts.
+func (r *reactionRepository) Add(ctx context.Context, postID, userID uuid.UUID, emoji string) error {
+	q := `
+		WITH added AS (
+			INSERT INTO social.post_reactions (post_id, user_id, emoji)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (post_id, user_id, emoji) DO NOTHING
+			RETURNING post_id, emoji
+		)
+		INSERT INTO social.post_reaction_counts (post_id, emoji, count)
+		SELECT post_id, emoji, 1 FROM added
+		ON CONFLICT (post_id, emoji) DO UPDATE SET count = social.post_reaction_counts.count + 1
+	`
+
+	if _, err := r.client.Exec(ctx, q, postID, userID, emoji); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("post not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Remove takes back a reaction. Removing a reaction that was not there is not an error.
+func (r *reactionRepository) Remove(ctx context.Context, postID, userID uuid.UUID, emoji string) error {
+	q := `
+		WITH removed AS (
+			DELETE FROM social.post_reactions
+			WHERE post_id = $1 AND user_id = $2 AND emoji = $3
+			RETURNING post_id, emoji
+		)
+		UPDATE social.post_reaction_counts c
+		SET count = c.count - 1
+		FROM removed
+		WHERE c.post_id = removed.post_id AND c.emoji = removed.emoji
+	`
+
+	_, err := r.client.Exec(ctx, q, postID, userID, emoji)
+	return err
+}
+
+// Counts returns how many reactions of each emoji the posts have. Posts without reactions are left out.
+func (r *reactionRepository) Counts(ctx context.Context, postIDs []uuid.UUID) (map[uuid.UUID]map[string]int, error) {
+	q := `
+		SELECT post_id, emoji, count
+		FROM social.post_reaction_counts
+		WHERE post_id = ANY($1) AND count > 0
+	`
+
+	rows, err := r.client.Query(ctx, q, postIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[uuid.UUID]map[string]int)
+	for rows.Next() {
+		var postID uuid.UUID
+		var emoji string
+		var count int
+		if err := rows.Scan(&postID, &emoji, &count); err != nil {
+			return nil, err
+		}
+
+		if counts[postID] == nil {
+			counts[postID] = make(map[string]int)
+		}
+		counts[postID][emoji] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// ByUser returns the emoji userID reacted to each of the posts with, oldest reaction first.
+func (r *reactionRepository) ByUser(
+	ctx context.Context,
+	userID uuid.UUID,
+	postIDs []uuid.UUID,
+) (map[uuid.UUID][]string, error) {
+	q := `
+		SELECT post_id, emoji
+		FROM social.post_reactions
+		WHERE user_id = $1 AND post_id = ANY($2)
+		ORDER BY created_at, emoji
+	`
+
+	rows, err := r.client.Query(ctx, q, userID, postIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reactions := make(map[uuid.UUID][]string)
+	for rows.Next() {
+		var postID uuid.UUID
+		var emoji string
+		if err := rows.Scan(&postID, &emoji); err != nil {
+			return nil, err
+		}
+		reactions[postID] = append(reactions[postID], emoji)
+	}
+
+	return reactions, rows.Err()
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type refreshTokenRepository struct {
+	client postgresql.Client
+}
+
+func NewRefreshTokenRepository(client postgresql.Client) repository.RefreshTokenRepository {
+	return &refreshTokenRepository{
+		client: client,
+	}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *entity.RefreshToken) error {
+	q := `
+		INSERT INTO social.refresh_tokens (user_id, family_id, token_hash, device, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	if err := r.client.QueryRow(ctx, q,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.Device,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	q := `
+		SELECT id, user_id, family_id, token_hash, device, expires_at, rotated_at, revoked_at, created_at
+		FROM social.refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token entity.RefreshToken
+	err := r.client.QueryRow(ctx, q, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.Device,
+		&token.ExpiresAt,
+		&token.RotatedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("refresh token not found")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Rotate marks the old token as used and stores its successor in one transaction.
+// Only one concurrent caller can win the rotation; the others get "refresh token already used".
+func (r *refreshTokenRepository) Rotate(ctx context.Context, oldID uuid.UUID, next *entity.RefreshToken) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		UPDATE social.refresh_tokens
+		SET rotated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`
+
+	ct, err := tx.Exec(ctx, q, oldID)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("refresh token already used")
+	}
+
+	q = `
+		INSERT INTO social.refresh_tokens (user_id, family_id, token_hash, device, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	if err := tx.QueryRow(ctx, q,
+		next.UserID,
+		next.FamilyID,
+		next.TokenHash,
+		next.Device,
+		next.ExpiresAt,
+	).Scan(&next.ID, &next.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
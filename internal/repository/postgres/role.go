@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type roleRepository struct {
+	client postgresql.Client
+}
+
+func NewRoleRepository(client postgresql.Client) repository.RoleRepository {
+	return &roleRepository{
+		client: client,
+	}
+}
+
+// ListByUser returns the roles of a user together with the permissions each of them grants.
+func (r *roleRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]entity.Role, error) {
+	q := `
+		SELECT ur.role,
+			COALESCE(array_agg(rp.permission ORDER BY rp.permission)
+				FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM social.user_roles ur
+		LEFT JOIN social.role_permissions rp ON rp.role = ur.role
+		WHERE ur.user_id = $1
+		GROUP BY ur.role
+		ORDER BY ur.role
+	`
+
+	rows, err := r.client.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]entity.Role, 0)
+	for rows.Next() {
+		var role entity.Role
+		if err := rows.Scan(&role.Name, &role.Permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// Assign grants a role to a user. Assigning a role the user already has is not an error.
+func (r *roleRepository) Assign(ctx context.Context, userID uuid.UUID, role string) error {
+	q := `
+		INSERT INTO social.user_roles (user_id, role)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, role) DO NOTHING
+	`
+
+	if _, err := r.client.Exec(ctx, q, userID, role); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			if pgErr.ConstraintName == "user_roles_role_fkey" {
+				return fmt.Errorf("role not found")
+			}
+			return fmt.Errorf("user not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *roleRepository) Remove(ctx context.Context, userID uuid.UUID, role string) error {
+	q := `
+		DELETE FROM social.user_roles
+		WHERE user_id = $1 AND role = $2
+	`
+
+	tag, err := r.client.Exec(ctx, q, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("role not assigned")
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type sessionRepository struct {
+	client postgresql.Client
+}
+
+func NewSessionRepository(client postgresql.Client) repository.SessionRepository {
+	return &sessionRepository{
+		client: client,
+	}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *entity.Session) error {
+	q := `
+		INSERT INTO social.sessions (user_id, user_agent, ip, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_used_at
+	`
+
+	if err := r.client.QueryRow(ctx, q,
+		session.UserID,
+		session.UserAgent,
+		session.IP,
+		session.ExpiresAt,
+	).Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) ListActive(ctx context.Context, userID uuid.UUID) ([]entity.Session, error) {
+	q := `
+		SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at
+		FROM social.sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_used_at DESC
+	`
+
+	rows, err := r.client.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]entity.Session, 0)
+	for rows.Next() {
+		var session entity.Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+			&session.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch records that the session was used again, typically when its refresh token is rotated.
+func (r *sessionRepository) Touch(ctx context.Context, session *entity.Session) error {
+	q := `
+		UPDATE social.sessions
+		SET user_agent = COALESCE(NULLIF($1, ''), user_agent),
+			ip = COALESCE(NULLIF($2, ''), ip),
+			expires_at = $3,
+			last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING last_used_at
+	`
+
+	if err := r.client.QueryRow(ctx, q,
+		session.UserAgent,
+		session.IP,
+		session.ExpiresAt,
+		session.ID,
+	).Scan(&session.LastUsedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("session not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) IsRevoked(ctx context.Context, id uuid.UUID) (bool, error) {
+	q := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM social.sessions
+			WHERE id = $1 AND revoked_at IS NOT NULL
+		)
+	`
+
+	var revoked bool
+	if err := r.client.QueryRow(ctx, q, id).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// Revoke revokes the session together with every refresh token issued for it.
+func (r *sessionRepository) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		UPDATE social.sessions
+		SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ct, err := tx.Exec(ctx, q, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	q = `
+		UPDATE social.refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := tx.Exec(ctx, q, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RevokeAllForUser revokes every active session of the user except the given one
+// (pass uuid.Nil to revoke all of them) and returns the IDs of the revoked sessions.
+func (r *sessionRepository) RevokeAllForUser(ctx context.Context, userID, except uuid.UUID) ([]uuid.UUID, error) {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	q := `
+		UPDATE social.sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+		RETURNING id
+	`
+
+	rows, err := tx.Query(ctx, q, userID, except)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	q = `
+		UPDATE social.refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL
+	`
+
+	if _, err := tx.Exec(ctx, q, userID, except); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+)
+
+type timelineRepository struct {
+	client postgresql.Client
+}
+
+func NewTimelineRepository(client postgresql.Client) repository.TimelineRepository {
+	return &timelineRepository{
+		client: client,
+	}
+}
+
+// FanOut claims up to limit posts waiting to be fanned out, oldest first, writes each to the timelines
+// of its author's followers unless the author has celebrityFollowers followers or more, and marks them
+// done. Skipped posts are marked as such and read when a feed loads, whatever the author's follower count
+// is by then. Workers running side by side skip each other's posts. It returns how many posts it claimed.
+func (r *timelineRepository) FanOut(ctx context.Context, limit, celebrityFollowers int) (int, error) {
+	q := `
+		WITH claimed AS (
+			SELECT id, user_id, created_at
+			FROM social.posts
+			WHERE fanned_out_at IS NULL
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), batch AS (
+			SELECT c.id, c.user_id, c.created_at, u.followers_count >= $2 AS skipped
+			FROM claimed c
+			JOIN social.users u ON u.id = c.user_id
+		), fanned AS (
+			INSERT INTO social.timelines (user_id, post_id, author_id, created_at)
+			SELECT f.follower_id, b.id, b.user_id, b.created_at
+			FROM batch b
+			JOIN social.follows f ON f.followee_id = b.user_id
+			WHERE NOT b.skipped
+			ON CONFLICT (user_id, post_id) DO NOTHING
+		)
+		UPDATE social.posts p
+		SET fanned_out_at = CURRENT_TIMESTAMP, fan_out_skipped = b.skipped
+		FROM batch b
+		WHERE p.id = b.id
+	`
+
+	tag, err := r.client.Exec(ctx, q, limit, celebrityFollowers)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// List returns up to limit posts for the home feed of userID, newest first, starting after the cursor.
+// It merges three sources: the timeline written by the fan-out worker, the newest posts of followed
+// authors the worker skipped for having too many followers, and followed authors' posts the worker
+// has not reached yet. Authors who have since been unfollowed or muted are left out.
+func (r *timelineRepository) List(
+	ctx context.Context,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.Post, error) {
+	q := `
+		(
+			SELECT p.id, p.user_id, p.content, p.reply_to_id, p.like_count, p.created_at, p.updated_at
+			FROM social.timelines t
+			JOIN social.posts p ON p.id = t.post_id
+			WHERE t.user_id = $1
+				AND ($2::timestamptz IS NULL OR (t.created_at, t.post_id) < ($2, $3::uuid))
+				AND EXISTS (
+					SELECT 1 FROM social.follows f
+					WHERE f.follower_id = $1 AND f.followee_id = t.author_id
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM social.mutes m
+					WHERE m.muter_id = $1 AND m.muted_id = t.author_id
+				)
+			ORDER BY t.created_at DESC, t.post_id DESC
+			LIMIT $4
+		)
+		UNION
+		(
+			SELECT p.id, p.user_id, p.content, p.reply_to_id, p.like_count, p.created_at, p.updated_at
+			FROM social.follows f
+			CROSS JOIN LATERAL (
+				SELECT id, user_id, content, reply_to_id, like_count, created_at, updated_at
+				FROM social.posts
+				WHERE user_id = f.followee_id
+					AND fan_out_skipped
+					AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3::uuid))
+				ORDER BY created_at DESC, id DESC
+				LIMIT $4
+			) p
+			WHERE f.follower_id = $1
+				AND NOT EXISTS (
+					SELECT 1 FROM social.mutes m
+					WHERE m.muter_id = $1 AND m.muted_id = f.followee_id
+				)
+		)
+		UNION
+		(
+			SELECT p.id, p.user_id, p.content, p.reply_to_id, p.like_count, p.created_at, p.updated_at
+			FROM social.posts p
+			JOIN social.follows f ON f.followee_id = p.user_id AND f.follower_id = $1
+			WHERE p.fanned_out_at IS NULL
+				AND ($2::timestamptz IS NULL OR (p.created_at, p.id) < ($2, $3::uuid))
+				AND NOT EXISTS (
+					SELECT 1 FROM social.mutes m
+					WHERE m.muter_id = $1 AND m.muted_id = p.user_id
+				)
+			ORDER BY p.created_at DESC, p.id DESC
+			LIMIT $4
+		)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`
+
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, &after.ID
+	}
+
+	rows, err := r.client.Query(ctx, q, userID, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectPosts(rows, limit)
+}
@@ -28,11 +28,11 @@ func (r *userRepository) Create(ctx context.Context, user *entity.User) error {
 	q := `
 		INSERT INTO social.users (username, email, password_hash)
 		VALUES ($1, $2, $3)
-		RETURNING id, created_at, updated_at
+		RETURNING id, birthday_visibility, created_at, updated_at
 	`
 
 	if err := r.client.QueryRow(ctx, q, user.Username, user.Email, user.PasswordHash).
-		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		Scan(&user.ID, &user.BirthdayVisibility, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			if pgErr.Code == "23505" {
@@ -47,7 +47,8 @@ func (r *userRepository) Create(ctx context.Context, user *entity.User) error {
 
 func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
 	q := `
-		SELECT id, username, email, password_hash, bio, birthday, created_at, updated_at
+		SELECT id, username, email, email_verified_at, password_hash, bio, birthday, birthday_visibility,
+			is_private, created_at, updated_at
 		FROM social.users
 		WHERE id = $1
 	`
@@ -57,9 +58,12 @@ func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Use
 		&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.EmailVerifiedAt,
 		&user.PasswordHash,
 		&user.Bio,
 		&user.Birthday,
+		&user.BirthdayVisibility,
+		&user.IsPrivate,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -75,7 +79,8 @@ func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Use
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
 	q := `
-		SELECT id, username, email, password_hash, bio, birthday, created_at, updated_at
+		SELECT id, username, email, email_verified_at, password_hash, bio, birthday, birthday_visibility,
+			is_private, created_at, updated_at
 		FROM social.users
 		WHERE email = $1
 	`
@@ -85,9 +90,12 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.
 		&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.EmailVerifiedAt,
 		&user.PasswordHash,
 		&user.Bio,
 		&user.Birthday,
+		&user.BirthdayVisibility,
+		&user.IsPrivate,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -104,9 +112,16 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entity.
 func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
 	q := `
 		UPDATE social.users
-		SET username = $1, email = $2, bio = $3, birthday = $4, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $5
-		RETURNING updated_at
+		SET username = $1,
+			email = $2,
+			email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+			bio = $3,
+			birthday = $4,
+			birthday_visibility = $5,
+			is_private = $6,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7
+		RETURNING email_verified_at, updated_at
 	`
 
 	err := r.client.QueryRow(ctx, q,
@@ -114,8 +129,10 @@ func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
 		user.Email,
 		user.Bio,
 		user.Birthday,
+		user.BirthdayVisibility,
+		user.IsPrivate,
 		user.ID,
-	).Scan(&user.UpdatedAt)
+	).Scan(&user.EmailVerifiedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
@@ -123,3 +140,102 @@ func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
 
 	return nil
 }
+
+const publicProfileQuery = `
+	SELECT u.id, u.username, u.bio, u.birthday, u.birthday_visibility,
+		u.followers_count, u.following_count,
+		(SELECT COUNT(*) FROM social.posts p WHERE p.user_id = u.id),
+		u.is_private, u.created_at
+	FROM social.users u
+`
+
+func (r *userRepository) GetPublicProfile(ctx context.Context, id uuid.UUID) (*entity.PublicProfile, error) {
+	return r.scanPublicProfile(r.client.QueryRow(ctx, publicProfileQuery+"WHERE u.id = $1", id))
+}
+
+func (r *userRepository) GetPublicProfileByUsername(
+	ctx context.Context,
+	username string,
+) (*entity.PublicProfile, error) {
+	return r.scanPublicProfile(r.client.QueryRow(ctx, publicProfileQuery+"WHERE u.username = $1", username))
+}
+
+func (r *userRepository) scanPublicProfile(row pgx.Row) (*entity.PublicProfile, error) {
+	var profile entity.PublicProfile
+	err := row.Scan(
+		&profile.ID,
+		&profile.Username,
+		&profile.Bio,
+		&profile.Birthday,
+		&profile.BirthdayVisibility,
+		&profile.FollowersCount,
+		&profile.FollowingCount,
+		&profile.PostsCount,
+		&profile.IsPrivate,
+		&profile.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// MarkEmailVerified confirms the email of the user, but only if it has not been changed
+// since the verification token was issued.
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) error {
+	q := `
+		UPDATE social.users
+		SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND email = $2
+	`
+
+	ct, err := r.client.Exec(ctx, q, id, email)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	q := `
+		UPDATE social.users
+		SET password_hash = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	ct, err := r.client.Exec(ctx, q, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	// The password is not changed, so updated_at is left alone. If the password was
+	// changed in the meantime, nothing is updated and the new password is kept.
+	q := `
+		UPDATE social.users
+		SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3
+	`
+
+	if _, err := r.client.Exec(ctx, q, newHash, id, oldHash); err != nil {
+		return fmt.Errorf("failed to replace password hash: %w", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/defskela/SocialNetwork/internal/entity"
 
@@ -12,7 +13,13 @@ type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
 	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+	GetPublicProfile(ctx context.Context, id uuid.UUID) (*entity.PublicProfile, error)
+	GetPublicProfileByUsername(ctx context.Context, username string) (*entity.PublicProfile, error)
 	Update(ctx context.Context, user *entity.User) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	// ReplacePasswordHash swaps the stored hash for newHash only if it is still oldHash.
+	ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error
 }
 
 type PostRepository interface {
@@ -20,16 +27,198 @@ type PostRepository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Post, error)
 	Update(ctx context.Context, post *entity.Post) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// ListFeed returns up to limit posts by the users userID follows, newest first, starting after the cursor.
+	// Posts by users userID has muted are left out.
+	ListFeed(ctx context.Context, userID uuid.UUID, after *entity.Cursor, limit int) ([]entity.Post, error)
+	// ListByUser returns up to limit posts by userID, newest first, starting after the cursor.
+	// A nil since or until leaves that end of the time range open. Replies are left out unless includeReplies.
+	ListByUser(
+		ctx context.Context,
+		userID uuid.UUID,
+		after *entity.Cursor,
+		since, until *time.Time,
+		includeReplies bool,
+		limit int,
+	) ([]entity.Post, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *entity.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+	Rotate(ctx context.Context, oldID uuid.UUID, next *entity.RefreshToken) error
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *entity.Session) error
+	ListActive(ctx context.Context, userID uuid.UUID) ([]entity.Session, error)
+	Touch(ctx context.Context, session *entity.Session) error
+	IsRevoked(ctx context.Context, id uuid.UUID) (bool, error)
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID, except uuid.UUID) ([]uuid.UUID, error)
+}
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *entity.PasswordResetToken) error
+	GetActive(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error)
+	Consume(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error)
+}
+
+type MFARepository interface {
+	SaveTOTP(ctx context.Context, totp *entity.TOTP) error
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*entity.TOTP, error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+}
+
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, scope, key string) (*entity.LoginThrottle, error)
+	RecordFailure(ctx context.Context, scope, key string, at, resetBefore time.Time) (int, error)
+	Block(ctx context.Context, scope, key string, until time.Time) error
+	Reset(ctx context.Context, scope, key string) error
+}
+
+type PersonalAccessTokenRepository interface {
+	Create(ctx context.Context, token *entity.PersonalAccessToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*entity.PersonalAccessToken, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]entity.PersonalAccessToken, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type RoleRepository interface {
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]entity.Role, error)
+	Assign(ctx context.Context, userID uuid.UUID, role string) error
+	Remove(ctx context.Context, userID uuid.UUID, role string) error
+}
+
+type OIDCRepository interface {
+	SaveState(ctx context.Context, state *entity.OIDCState) error
+	ConsumeState(ctx context.Context, stateHash string) (*entity.OIDCState, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*entity.UserIdentity, error)
+	LinkIdentity(ctx context.Context, identity *entity.UserIdentity) error
+	CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.UserIdentity) error
+}
+
+type MagicLinkRepository interface {
+	Create(ctx context.Context, link *entity.MagicLink) error
+	Consume(ctx context.Context, id uuid.UUID) (*entity.MagicLink, error)
+}
+
+type FollowRepository interface {
+	Follow(ctx context.Context, followerID, followeeID uuid.UUID) (bool, error)
+	Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) (bool, error)
+	// ListFollowers and ListFollowing return up to limit users, newest follow first, starting after the cursor.
+	// Users on either side of a block with viewerID are left out.
+	ListFollowers(
+		ctx context.Context,
+		viewerID, userID uuid.UUID,
+		after *entity.Cursor,
+		limit int,
+	) ([]entity.FollowUser, error)
+	ListFollowing(
+		ctx context.Context,
+		viewerID, userID uuid.UUID,
+		after *entity.Cursor,
+		limit int,
+	) ([]entity.FollowUser, error)
+	IsFollowing(ctx context.Context, followerID, followeeID uuid.UUID) (bool, error)
+	Request(ctx context.Context, requesterID, targetID uuid.UUID) error
+	DeleteRequest(ctx context.Context, requesterID, targetID uuid.UUID) error
+	AcceptRequest(ctx context.Context, requesterID, targetID uuid.UUID) error
+	// ListRequests returns pending requests to follow targetID, newest first, starting after the cursor.
+	ListRequests(ctx context.Context, targetID uuid.UUID, after *entity.Cursor, limit int) ([]entity.FollowUser, error)
+}
+
+// BlockRepository keeps the users each user has blocked or muted.
+type BlockRepository interface {
+	Block(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	Unblock(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	IsBlocked(ctx context.Context, a, b uuid.UUID) (bool, error)
+	// ListBlocked and ListMuted return up to limit users, newest first, starting after the cursor.
+	ListBlocked(ctx context.Context, userID uuid.UUID, after *entity.Cursor, limit int) ([]entity.RestrictedUser, error)
+	Mute(ctx context.Context, muterID, mutedID uuid.UUID) error
+	Unmute(ctx context.Context, muterID, mutedID uuid.UUID) error
+	ListMuted(ctx context.Context, userID uuid.UUID, after *entity.Cursor, limit int) ([]entity.RestrictedUser, error)
+}
+
+// TimelineRepository keeps the home feeds written ahead by fan-out on write.
+type TimelineRepository interface {
+	FanOut(ctx context.Context, limit, celebrityFollowers int) (int, error)
+	List(ctx context.Context, userID uuid.UUID, after *entity.Cursor, limit int) ([]entity.Post, error)
+}
+
+type LikeRepository interface {
+	Like(ctx context.Context, postID, userID uuid.UUID) (int, error)
+	Unlike(ctx context.Context, postID, userID uuid.UUID) (int, error)
+	LikedBy(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID) ([]uuid.UUID, error)
+	// ListLikers returns up to limit users who liked postID, newest like first, starting after the cursor.
+	// Users who blocked viewerID, or whom viewerID blocked, are left out.
+	ListLikers(ctx context.Context, viewerID, postID uuid.UUID, after *entity.Cursor, limit int) ([]entity.Liker, error)
+}
+
+// ReactionRepository keeps the emoji reactions on posts and their counts.
+type ReactionRepository interface {
+	Add(ctx context.Context, postID, userID uuid.UUID, emoji string) error
+	Remove(ctx context.Context, postID, userID uuid.UUID, emoji string) error
+	Counts(ctx context.Context, postIDs []uuid.UUID) (map[uuid.UUID]map[string]int, error)
+	ByUser(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID][]string, error)
 }
 
 type Repository struct {
-	User UserRepository
-	Post PostRepository
+	User          UserRepository
+	Post          PostRepository
+	RefreshToken  RefreshTokenRepository
+	Session       SessionRepository
+	PasswordReset PasswordResetRepository
+	MFA           MFARepository
+	LoginThrottle LoginThrottleRepository
+	AccessToken   PersonalAccessTokenRepository
+	Role          RoleRepository
+	OIDC          OIDCRepository
+	MagicLink     MagicLinkRepository
+	Follow        FollowRepository
+	Block         BlockRepository
+	Timeline      TimelineRepository
+	Like          LikeRepository
+	Reaction      ReactionRepository
 }
 
-func NewRepository(user UserRepository, post PostRepository) *Repository {
+func NewRepository(
+	user UserRepository,
+	post PostRepository,
+	refreshToken RefreshTokenRepository,
+	session SessionRepository,
+	passwordReset PasswordResetRepository,
+	mfa MFARepository,
+	loginThrottle LoginThrottleRepository,
+	accessToken PersonalAccessTokenRepository,
+	role RoleRepository,
+	oidc OIDCRepository,
+	magicLink MagicLinkRepository,
+	follow FollowRepository,
+	block BlockRepository,
+	timeline TimelineRepository,
+	like LikeRepository,
+	reaction ReactionRepository,
+) *Repository {
 	return &Repository{
-		User: user,
-		Post: post,
+		User:          user,
+		Post:          post,
+		RefreshToken:  refreshToken,
+		Session:       session,
+		PasswordReset: passwordReset,
+		MFA:           mfa,
+		LoginThrottle: loginThrottle,
+		AccessToken:   accessToken,
+		Role:          role,
+		OIDC:          oidc,
+		MagicLink:     magicLink,
+		Follow:        follow,
+		Block:         block,
+		Timeline:      timeline,
+		Like:          like,
+		Reaction:      reaction,
 	}
 }
@@ -3,67 +3,222 @@ package service
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/defskela/SocialNetwork/internal/entity"
 	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/cache"
+	"github.com/defskela/SocialNetwork/pkg/hasher"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
+	"github.com/defskela/SocialNetwork/pkg/oidc"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	errInvalidRefreshToken = "invalid refresh token"
+	errRefreshTokenExpired = "refresh token expired"
+	errRefreshTokenReused  = "refresh token reused"
+	errSessionRevoked      = "session revoked"
+)
+
+const (
+	revocationCacheSize = 10000
+	maxDeviceLength     = 255
+	oidcRequestTimeout  = 10 * time.Second
+)
+
 type AuthClaim struct {
 	jwt.RegisteredClaims
-	UserID uuid.UUID `json:"user_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	SessionID uuid.UUID `json:"sid"`
+	// Roles and Permissions are what the user was granted when the token was issued.
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// AccessTokenID and Scopes are only set when the caller used a personal access token.
+	AccessTokenID uuid.UUID `json:"-"`
+	Scopes        []string  `json:"-"`
+}
+
+// IsPersonalAccessToken reports whether the claims come from a personal access token rather than a session.
+func (c *AuthClaim) IsPersonalAccessToken() bool {
+	return c.AccessTokenID != uuid.Nil
+}
+
+// HasScope reports whether the caller may act within scope. Sessions may do anything.
+func (c *AuthClaim) HasScope(scope string) bool {
+	if !c.IsPersonalAccessToken() {
+		return true
+	}
+
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the token grants permission. Personal access tokens carry no permissions.
+func (c *AuthClaim) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type AuthConfig struct {
+	// Keys signs and verifies tokens. When nil, a ring holding the single
+	// key pair at PrivateKeyPath and PublicKeyPath is used.
+	Keys *keyring.Ring
+	// Algorithm is the JWS algorithm tokens are signed with: RS256, ES256 or EdDSA.
+	Algorithm       string
+	PrivateKeyPath  string
+	PublicKeyPath   string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// Issuer and Audience go into the iss and aud claims of access tokens, and ParseToken requires them.
+	Issuer   string
+	Audience string
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
+	// PublicURL is the base URL used in links sent to users by email.
+	PublicURL            string
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	PasswordPolicy       PasswordPolicyConfig
+	// PasswordHasher hashes new passwords and upgrades old hashes on sign in. When nil,
+	// passwords are hashed with argon2id and existing bcrypt hashes still verify.
+	PasswordHasher hasher.PasswordHasher
+	LoginThrottle  LoginThrottleConfig
+	// MFAChallengeTTL is how long a user has to enter their second factor after the password.
+	MFAChallengeTTL time.Duration
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string
+	// RevocationCacheTTL bounds how long a replica may keep trusting a session
+	// that was revoked through another replica.
+	RevocationCacheTTL time.Duration
+	// OIDCProviders are the external providers users may sign in with, by name.
+	OIDCProviders map[string]oidc.Config
+	// OIDCStateTTL is how long a user has to come back from the provider.
+	OIDCStateTTL time.Duration
+	MagicLink    MagicLinkConfig
 }
 
 type authService struct {
-	userRepo   repository.UserRepository
-	tokenTTL   time.Duration
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
-}
-
-func NewAuthService(
-	userRepo repository.UserRepository,
-	tokenTTL time.Duration,
-	privKeyPath, pubKeyPath string,
-) (AuthService, error) {
-	privBytes, err := os.ReadFile(privKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("could not read private key file: %w", err)
+	userRepo             repository.UserRepository
+	refreshRepo          repository.RefreshTokenRepository
+	sessionRepo          repository.SessionRepository
+	resetRepo            repository.PasswordResetRepository
+	mfaRepo              repository.MFARepository
+	throttleRepo         repository.LoginThrottleRepository
+	accessTokenRepo      repository.PersonalAccessTokenRepository
+	access               *accessPolicy
+	oidcRepo             repository.OIDCRepository
+	oidcProviders        map[string]*oidc.Provider
+	oidcStateTTL         time.Duration
+	magicLinkRepo        repository.MagicLinkRepository
+	magicLink            MagicLinkConfig
+	mailer               mailer.Mailer
+	passwordPolicy       *passwordPolicy
+	hasher               hasher.PasswordHasher
+	throttle             LoginThrottleConfig
+	tokenTTL             time.Duration
+	issuer               string
+	audience             string
+	leeway               time.Duration
+	refreshTokenTTL      time.Duration
+	publicURL            string
+	emailVerificationTTL time.Duration
+	passwordResetTTL     time.Duration
+	mfaChallengeTTL      time.Duration
+	totpIssuer           string
+	revocationCacheTTL   time.Duration
+	revocations          *cache.TTL[uuid.UUID, bool]
+	keys                 *keyring.Ring
+	now                  func() time.Time
+}
+
+func NewAuthService(repos *repository.Repository, mail mailer.Mailer, cfg AuthConfig) (AuthService, error) {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("token issuer and audience are required")
 	}
 
-	privKey, err := jwt.ParseRSAPrivateKeyFromPEM(privBytes)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse private key: %w", err)
+	keys := cfg.Keys
+	if keys == nil {
+		var err error
+		keys, err = keyring.FromFiles(cfg.PrivateKeyPath, cfg.PublicKeyPath, cfg.Algorithm)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	pubBytes, err := os.ReadFile(pubKeyPath)
+	policy, err := newPasswordPolicy(cfg.PasswordPolicy)
 	if err != nil {
-		return nil, fmt.Errorf("could not read public key file: %w", err)
+		return nil, err
 	}
 
-	pubKey, err := jwt.ParseRSAPublicKeyFromPEM(pubBytes)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse public key: %w", err)
+	passwordHasher := cfg.PasswordHasher
+	if passwordHasher == nil {
+		passwordHasher, err = hasher.New(hasher.Argon2id, hasher.DefaultArgon2idParams, bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for name, providerCfg := range cfg.OIDCProviders {
+		providers[name] = oidc.NewProvider(providerCfg, &http.Client{Timeout: oidcRequestTimeout})
 	}
 
 	return &authService{
-		userRepo:   userRepo,
-		tokenTTL:   tokenTTL,
-		privateKey: privKey,
-		publicKey:  pubKey,
+		userRepo:             repos.User,
+		refreshRepo:          repos.RefreshToken,
+		sessionRepo:          repos.Session,
+		resetRepo:            repos.PasswordReset,
+		mfaRepo:              repos.MFA,
+		throttleRepo:         repos.LoginThrottle,
+		accessTokenRepo:      repos.AccessToken,
+		access:               newAccessPolicy(repos.Role),
+		oidcRepo:             repos.OIDC,
+		oidcProviders:        providers,
+		oidcStateTTL:         cfg.OIDCStateTTL,
+		magicLinkRepo:        repos.MagicLink,
+		magicLink:            cfg.MagicLink,
+		mailer:               mail,
+		passwordPolicy:       policy,
+		hasher:               passwordHasher,
+		throttle:             cfg.LoginThrottle,
+		tokenTTL:             cfg.AccessTokenTTL,
+		issuer:               cfg.Issuer,
+		audience:             cfg.Audience,
+		leeway:               cfg.Leeway,
+		refreshTokenTTL:      cfg.RefreshTokenTTL,
+		publicURL:            strings.TrimRight(cfg.PublicURL, "/"),
+		emailVerificationTTL: cfg.EmailVerificationTTL,
+		passwordResetTTL:     cfg.PasswordResetTTL,
+		mfaChallengeTTL:      cfg.MFAChallengeTTL,
+		totpIssuer:           cfg.TOTPIssuer,
+		revocationCacheTTL:   cfg.RevocationCacheTTL,
+		revocations:          cache.NewTTL[uuid.UUID, bool](revocationCacheSize),
+		keys:                 keys,
+		now:                  time.Now,
 	}, nil
 }
 
 func (s *authService) SignUp(ctx context.Context, input SignUpInput) (uuid.UUID, error) {
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	passwordHash, err := s.hasher.Hash(input.Password)
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -71,66 +226,315 @@ func (s *authService) SignUp(ctx context.Context, input SignUpInput) (uuid.UUID,
 	user := &entity.User{
 		Username:     input.Username,
 		Email:        input.Email,
-		PasswordHash: string(passwordHash),
+		PasswordHash: passwordHash,
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return uuid.Nil, err
 	}
 
+	s.trySendVerificationEmail(ctx, user)
+
 	return user.ID, nil
 }
 
+// SignIn checks the password and opens a session. Failed attempts are counted per account and per IP,
+// and further attempts are refused with a ThrottledError once there have been too many.
 func (s *authService) SignIn(ctx context.Context, input SignInInput) (Tokens, error) {
 	user, err := s.userRepo.GetByEmail(ctx, input.Email)
+	if err != nil && err.Error() != "user not found" {
+		return Tokens{}, err
+	}
+
+	var accountKey string
+	if user != nil {
+		accountKey = user.ID.String()
+	}
+
+	if throttleErr := s.checkThrottle(ctx, accountKey, input.IP); throttleErr != nil {
+		return Tokens{}, throttleErr
+	}
+
+	if user == nil {
+		return Tokens{}, s.failSignIn(ctx, "", input.IP, err)
+	}
+
+	if pwdErr := s.hasher.Verify(user.PasswordHash, input.Password); pwdErr != nil {
+		return Tokens{}, s.failSignIn(ctx, accountKey, input.IP, errors.New(errInvalidPassword))
+	}
+
+	s.rehashPassword(ctx, user, input.Password)
+
+	enrollment, err := s.confirmedTOTP(ctx, user.ID)
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	if pwdErr := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); pwdErr != nil {
-		return Tokens{}, fmt.Errorf("invalid password")
+	// The counters are only reset once the second factor is in too, so knowing
+	// the password does not give unlimited guesses at the code.
+	if enrollment != nil {
+		return s.mfaChallenge(user.ID)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, &AuthClaim{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenTTL)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-		UserID: user.ID,
-	})
+	if err := s.resetThrottle(ctx, accountKey); err != nil {
+		return Tokens{}, err
+	}
+
+	return s.startSession(ctx, user.ID, input.Device, input.IP)
+}
 
-	accessToken, err := token.SignedString(s.privateKey)
+// failSignIn records a failed attempt and returns cause.
+func (s *authService) failSignIn(ctx context.Context, accountKey, ip string, cause error) error {
+	if err := s.recordFailure(ctx, accountKey, ip); err != nil {
+		return err
+	}
+
+	return cause
+}
+
+// startSession opens a new session for a user who has been fully authenticated.
+func (s *authService) startSession(ctx context.Context, userID uuid.UUID, device, ip string) (Tokens, error) {
+	device = truncate(device, maxDeviceLength)
+	session := &entity.Session{
+		UserID:    userID,
+		UserAgent: device,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return Tokens{}, err
+	}
+
+	tokens, refreshToken, err := s.newTokens(ctx, userID, session.ID, device)
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	refreshToken := make([]byte, 32)
-	if _, err := rand.Read(refreshToken); err != nil {
+	if err := s.refreshRepo.Create(ctx, refreshToken); err != nil {
 		return Tokens{}, err
 	}
 
-	return Tokens{
-		AccessToken:  accessToken,
-		RefreshToken: hex.EncodeToString(refreshToken),
-	}, nil
+	return tokens, nil
 }
 
-func (s *authService) ParseToken(accessToken string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &AuthClaim{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// Refresh trades a valid refresh token for a new token pair, rotating the refresh token.
+// Presenting a token that was already rotated means it leaked, so the whole family is revoked.
+func (s *authService) Refresh(ctx context.Context, input RefreshInput) (Tokens, error) {
+	current, err := s.refreshRepo.GetByHash(ctx, hashToken(input.RefreshToken))
+	if err != nil {
+		if err.Error() == "refresh token not found" {
+			return Tokens{}, errors.New(errInvalidRefreshToken)
 		}
-		return s.publicKey, nil
-	})
+		return Tokens{}, err
+	}
+
+	if current.RevokedAt != nil {
+		return Tokens{}, errors.New(errInvalidRefreshToken)
+	}
+
+	if current.RotatedAt != nil {
+		return Tokens{}, s.revokeReusedFamily(ctx, current.UserID, current.FamilyID)
+	}
 
+	if time.Now().After(current.ExpiresAt) {
+		return Tokens{}, errors.New(errRefreshTokenExpired)
+	}
+
+	device := truncate(input.Device, maxDeviceLength)
+	if device == "" {
+		device = current.Device
+	}
+
+	tokens, next, err := s.newTokens(ctx, current.UserID, current.FamilyID, device)
 	if err != nil {
-		return uuid.Nil, err
+		return Tokens{}, err
+	}
+
+	if err := s.refreshRepo.Rotate(ctx, current.ID, next); err != nil {
+		if err.Error() == "refresh token already used" {
+			return Tokens{}, s.revokeReusedFamily(ctx, current.UserID, current.FamilyID)
+		}
+		return Tokens{}, err
+	}
+
+	if err := s.sessionRepo.Touch(ctx, &entity.Session{
+		ID:        current.FamilyID,
+		UserAgent: device,
+		IP:        input.IP,
+		ExpiresAt: next.ExpiresAt,
+	}); err != nil {
+		return Tokens{}, err
+	}
+
+	return tokens, nil
+}
+
+func (s *authService) Logout(ctx context.Context, userID, sessionID uuid.UUID) error {
+	if err := s.sessionRepo.Revoke(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	s.markRevoked(sessionID)
+
+	return nil
+}
+
+func (s *authService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	return s.revokeSessionsExcept(ctx, userID, uuid.Nil)
+}
+
+func (s *authService) revokeSessionsExcept(ctx context.Context, userID, keepSessionID uuid.UUID) error {
+	ids, err := s.sessionRepo.RevokeAllForUser(ctx, userID, keepSessionID)
+	if err != nil {
+		return err
+	}
+
+	s.markRevoked(ids...)
+
+	return nil
+}
+
+func (s *authService) ListSessions(
+	ctx context.Context,
+	userID, currentSessionID uuid.UUID,
+) ([]entity.Session, error) {
+	sessions, err := s.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+// ParseToken verifies the access token and rejects it if its session has been revoked.
+// Personal access tokens are accepted too.
+func (s *authService) ParseToken(ctx context.Context, accessToken string) (*AuthClaim, error) {
+	if isPersonalAccessToken(accessToken) {
+		return s.parsePersonalAccessToken(ctx, accessToken)
+	}
+
+	token, err := s.parse(accessToken, &AuthClaim{}, jwt.WithAudience(s.audience))
+	if err != nil {
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(*AuthClaim)
-	if !ok || !token.Valid {
-		return uuid.Nil, fmt.Errorf("invalid token claims")
+	if !ok || !token.Valid || claims.SessionID == uuid.Nil || claims.Subject != claims.UserID.String() {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	revoked, err := s.isSessionRevoked(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.New(errSessionRevoked)
+	}
+
+	return claims, nil
+}
+
+// isSessionRevoked consults the revocation cache before hitting the database.
+// Revoked sessions never come back, so they are cached for as long as an access token lives.
+func (s *authService) isSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	if revoked, ok := s.revocations.Get(sessionID); ok {
+		return revoked, nil
+	}
+
+	revoked, err := s.sessionRepo.IsRevoked(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	ttl := s.revocationCacheTTL
+	if revoked {
+		ttl = s.tokenTTL
 	}
+	s.revocations.Set(sessionID, revoked, ttl)
+
+	return revoked, nil
+}
+
+func (s *authService) markRevoked(sessionIDs ...uuid.UUID) {
+	for _, id := range sessionIDs {
+		s.revocations.Set(id, true, s.tokenTTL)
+	}
+}
+
+func (s *authService) revokeReusedFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	if err := s.Logout(ctx, userID, familyID); err != nil {
+		return err
+	}
+
+	return errors.New(errRefreshTokenReused)
+}
+
+// newTokens signs an access token and generates a refresh token belonging to familyID.
+// The returned entity holds only the hash of the refresh token and still has to be persisted.
+func (s *authService) newTokens(
+	ctx context.Context,
+	userID, familyID uuid.UUID,
+	device string,
+) (Tokens, *entity.RefreshToken, error) {
+	roles, permissions, err := s.access.grants(ctx, userID)
+	if err != nil {
+		return Tokens{}, nil, err
+	}
+
+	now := time.Now()
+	accessToken, err := s.sign(&AuthClaim{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    s.issuer,
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{s.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.tokenTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		UserID:      userID,
+		SessionID:   familyID,
+		Roles:       roles,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return Tokens{}, nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return Tokens{}, nil, err
+	}
+	refreshToken := hex.EncodeToString(raw)
+
+	tokens := Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+
+	return tokens, &entity.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		Device:    device,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	}, nil
+}
+
+func truncate(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	return string(runes[:maxLen])
+}
 
-	return claims.UserID, nil
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
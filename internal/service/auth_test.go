@@ -2,28 +2,116 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/defskela/SocialNetwork/internal/config"
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
 	"github.com/defskela/SocialNetwork/internal/repository/postgres"
 	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+	"github.com/defskela/SocialNetwork/pkg/hasher"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
+	"github.com/defskela/SocialNetwork/pkg/totp"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthServiceVerifySuite struct {
 	suite.Suite
 	pool        *pgxpool.Pool
 	authService AuthService
+	mailer      *mailer.Memory
 	privKeyPath string
 	pubKeyPath  string
 }
 
 const testDBHost = "localhost"
 
+func newTestAuthConfig(privKeyPath, pubKeyPath string) AuthConfig {
+	return AuthConfig{
+		Algorithm:            keyring.RS256,
+		PrivateKeyPath:       privKeyPath,
+		PublicKeyPath:        pubKeyPath,
+		AccessTokenTTL:       time.Hour,
+		Issuer:               "http://localhost:8080",
+		Audience:             "social-network-api",
+		Leeway:               time.Second,
+		RefreshTokenTTL:      24 * time.Hour,
+		PublicURL:            "http://localhost:8080",
+		EmailVerificationTTL: time.Hour,
+		PasswordResetTTL:     time.Hour,
+		RevocationCacheTTL:   time.Second,
+		PasswordPolicy:       PasswordPolicyConfig{MinLength: 8},
+		MFAChallengeTTL:      5 * time.Minute,
+		TOTPIssuer:           "SocialNetwork",
+		MagicLink:            MagicLinkConfig{TTL: 5 * time.Minute, MaxRequests: 3, Window: 15 * time.Minute},
+	}
+}
+
+// tokenFromMessage extracts the token query parameter from a link in an email body.
+func tokenFromMessage(body string) string {
+	match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(body)
+	if match == nil {
+		return ""
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		return ""
+	}
+
+	return token
+}
+
+func newTestRepository(pool *pgxpool.Pool) *repository.Repository {
+	return repository.NewRepository(
+		postgres.NewUserRepository(pool),
+		postgres.NewPostRepository(pool),
+		postgres.NewRefreshTokenRepository(pool),
+		postgres.NewSessionRepository(pool),
+		postgres.NewPasswordResetRepository(pool),
+		postgres.NewMFARepository(pool),
+		postgres.NewLoginThrottleRepository(pool),
+		postgres.NewPersonalAccessTokenRepository(pool),
+		postgres.NewRoleRepository(pool),
+		postgres.NewOIDCRepository(pool),
+		postgres.NewMagicLinkRepository(pool),
+		postgres.NewFollowRepository(pool),
+		postgres.NewBlockRepository(pool),
+		postgres.NewTimelineRepository(pool),
+		postgres.NewLikeRepository(pool),
+		postgres.NewReactionRepository(pool),
+	)
+}
+
+// createTestUsers stores n users whose names start with prefix and are unique across runs.
+func createTestUsers(t *testing.T, repo repository.UserRepository, prefix string, n int) []*entity.User {
+	users := make([]*entity.User, n)
+	for i := range users {
+		name := prefix + uuid.New().String()
+		users[i] = &entity.User{Username: name, Email: name + "@example.com", PasswordHash: "hash"}
+		require.NoError(t, repo.Create(context.Background(), users[i]))
+	}
+	return users
+}
+
 func (s *AuthServiceVerifySuite) SetupSuite() {
 	cfg := config.MustLoadPath("../../configs/local.yaml")
 	cfg.Postgres.Host = testDBHost
@@ -43,9 +131,11 @@ func (s *AuthServiceVerifySuite) SetupTest() {
 	s.privKeyPath = "../../certs/local/private.pem"
 	s.pubKeyPath = "../../certs/local/public.pem"
 
-	authRepo := postgres.NewUserRepository(s.pool)
+	s.mailer = mailer.NewMemory()
+
 	var err error
-	s.authService, err = NewAuthService(authRepo, time.Hour, s.privKeyPath, s.pubKeyPath)
+	cfg := newTestAuthConfig(s.privKeyPath, s.pubKeyPath)
+	s.authService, err = NewAuthService(newTestRepository(s.pool), s.mailer, cfg)
 	s.Require().NoError(err)
 }
 
@@ -88,6 +178,536 @@ func (s *AuthServiceVerifySuite) TestSignIn() {
 	s.NotEmpty(tokens.AccessToken)
 }
 
+func (s *AuthServiceVerifySuite) TestRefresh() {
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+
+	_, err := s.authService.SignUp(context.Background(), input)
+	s.Require().NoError(err)
+
+	tokens, err := s.authService.SignIn(context.Background(), SignInInput{
+		Email:    input.Email,
+		Password: input.Password,
+		Device:   "test-device",
+	})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(tokens.RefreshToken)
+
+	rotated, err := s.authService.Refresh(context.Background(), RefreshInput{RefreshToken: tokens.RefreshToken})
+	s.Require().NoError(err)
+	s.NotEmpty(rotated.AccessToken)
+	s.NotEqual(tokens.RefreshToken, rotated.RefreshToken)
+
+	_, err = s.authService.Refresh(context.Background(), RefreshInput{RefreshToken: tokens.RefreshToken})
+	s.Error(err)
+	s.Equal(errRefreshTokenReused, err.Error())
+
+	_, err = s.authService.Refresh(context.Background(), RefreshInput{RefreshToken: rotated.RefreshToken})
+	s.Error(err)
+	s.Equal(errInvalidRefreshToken, err.Error())
+
+	_, err = s.authService.Refresh(context.Background(), RefreshInput{RefreshToken: "unknown"})
+	s.Error(err)
+	s.Equal(errInvalidRefreshToken, err.Error())
+}
+
+func (s *AuthServiceVerifySuite) TestLogout() {
+	ctx := context.Background()
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+
+	userID, err := s.authService.SignUp(ctx, input)
+	s.Require().NoError(err)
+
+	signIn := SignInInput{Email: input.Email, Password: input.Password}
+	first, err := s.authService.SignIn(ctx, signIn)
+	s.Require().NoError(err)
+	second, err := s.authService.SignIn(ctx, signIn)
+	s.Require().NoError(err)
+
+	claims, err := s.authService.ParseToken(ctx, first.AccessToken)
+	s.Require().NoError(err)
+	s.Equal(userID, claims.UserID)
+	s.NotEmpty(claims.ID)
+
+	s.Require().NoError(s.authService.Logout(ctx, userID, claims.SessionID))
+
+	_, err = s.authService.ParseToken(ctx, first.AccessToken)
+	s.Error(err)
+	s.Equal(errSessionRevoked, err.Error())
+
+	_, err = s.authService.Refresh(ctx, RefreshInput{RefreshToken: first.RefreshToken})
+	s.Error(err)
+
+	_, err = s.authService.ParseToken(ctx, second.AccessToken)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.authService.LogoutAll(ctx, userID))
+
+	_, err = s.authService.ParseToken(ctx, second.AccessToken)
+	s.Error(err)
+	s.Equal(errSessionRevoked, err.Error())
+}
+
+func (s *AuthServiceVerifySuite) TestVerifyEmail() {
+	ctx := context.Background()
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+
+	id, err := s.authService.SignUp(ctx, input)
+	s.Require().NoError(err)
+
+	msg, ok := s.mailer.Last(input.Email)
+	s.Require().True(ok)
+	token := tokenFromMessage(msg.Body)
+	s.Require().NotEmpty(token)
+
+	err = s.authService.VerifyEmail(ctx, VerifyEmailInput{Token: token + "x"})
+	s.Error(err)
+	s.Equal(errInvalidVerificationToken, err.Error())
+
+	s.Require().NoError(s.authService.VerifyEmail(ctx, VerifyEmailInput{Token: token}))
+
+	user, err := postgres.NewUserRepository(s.pool).GetByID(ctx, id)
+	s.Require().NoError(err)
+	s.NotNil(user.EmailVerifiedAt)
+
+	sent := len(s.mailer.Sent())
+	s.Require().NoError(s.authService.ResendVerification(ctx, ResendVerificationInput{Email: input.Email}))
+	s.Require().NoError(s.authService.ResendVerification(ctx, ResendVerificationInput{Email: "nobody@example.com"}))
+	s.Len(s.mailer.Sent(), sent)
+}
+
+func (s *AuthServiceVerifySuite) TestPasswordReset() {
+	ctx := context.Background()
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+
+	_, err := s.authService.SignUp(ctx, input)
+	s.Require().NoError(err)
+
+	tokens, err := s.authService.SignIn(ctx, SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+
+	sent := len(s.mailer.Sent())
+	s.Require().NoError(s.authService.ForgotPassword(ctx, ForgotPasswordInput{Email: "nobody@example.com"}))
+	s.Len(s.mailer.Sent(), sent)
+
+	s.Require().NoError(s.authService.ForgotPassword(ctx, ForgotPasswordInput{Email: input.Email}))
+	msg, ok := s.mailer.Last(input.Email)
+	s.Require().True(ok)
+	resetToken := tokenFromMessage(msg.Body)
+	s.Require().NotEmpty(resetToken)
+
+	// A password the policy rejects leaves the token usable.
+	err = s.authService.ResetPassword(ctx, ResetPasswordInput{Token: resetToken, Password: "short"})
+	s.Require().Error(err)
+	s.NotEqual(errInvalidResetToken, err.Error())
+
+	s.Require().NoError(s.authService.ResetPassword(ctx, ResetPasswordInput{
+		Token:    resetToken,
+		Password: "newPassword456",
+	}))
+
+	err = s.authService.ResetPassword(ctx, ResetPasswordInput{Token: resetToken, Password: "otherPassword789"})
+	s.Error(err)
+	s.Equal(errInvalidResetToken, err.Error())
+
+	_, err = s.authService.ParseToken(ctx, tokens.AccessToken)
+	s.Error(err)
+	s.Equal(errSessionRevoked, err.Error())
+
+	_, err = s.authService.SignIn(ctx, SignInInput{Email: input.Email, Password: input.Password})
+	s.Error(err)
+
+	_, err = s.authService.SignIn(ctx, SignInInput{Email: input.Email, Password: "newPassword456"})
+	s.NoError(err)
+}
+
+func (s *AuthServiceVerifySuite) TestTOTP() {
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+
+	userID, err := s.authService.SignUp(context.Background(), input)
+	s.Require().NoError(err)
+
+	now := time.Now()
+	s.authService.(*authService).now = func() time.Time { return now }
+
+	enrollment, err := s.authService.EnrollTOTP(context.Background(), userID)
+	s.Require().NoError(err)
+	s.Contains(enrollment.URI, "otpauth://totp/")
+
+	// An unconfirmed enrollment does not change sign in.
+	tokens, err := s.authService.SignIn(context.Background(), SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+	s.False(tokens.MFARequired)
+
+	_, err = s.authService.ConfirmTOTP(context.Background(), userID, TOTPCodeInput{Code: "000000"})
+	s.EqualError(err, errInvalidMFACode)
+
+	code, err := totp.Code(enrollment.Secret, now)
+	s.Require().NoError(err)
+
+	recovery, err := s.authService.ConfirmTOTP(context.Background(), userID, TOTPCodeInput{Code: code})
+	s.Require().NoError(err)
+	s.Len(recovery.Codes, recoveryCodeCount)
+
+	_, err = s.authService.EnrollTOTP(context.Background(), userID)
+	s.EqualError(err, errMFAAlreadyEnabled)
+
+	challenge, err := s.authService.SignIn(context.Background(), SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+	s.True(challenge.MFARequired)
+	s.NotEmpty(challenge.MFAToken)
+	s.Empty(challenge.AccessToken)
+
+	// The code that confirmed the enrollment cannot be replayed.
+	_, err = s.authService.SignInMFA(context.Background(), SignInMFAInput{MFAToken: challenge.MFAToken, Code: code})
+	s.EqualError(err, errInvalidMFACode)
+
+	now = now.Add(totp.Period)
+	code, err = totp.Code(enrollment.Secret, now)
+	s.Require().NoError(err)
+
+	tokens, err = s.authService.SignInMFA(context.Background(), SignInMFAInput{MFAToken: challenge.MFAToken, Code: code})
+	s.Require().NoError(err)
+	s.NotEmpty(tokens.AccessToken)
+	s.NotEmpty(tokens.RefreshToken)
+
+	_, err = s.authService.SignInMFA(context.Background(), SignInMFAInput{MFAToken: "garbage", Code: code})
+	s.EqualError(err, errInvalidMFAToken)
+
+	// Recovery codes work once, with or without the dash.
+	recoveryInput := SignInMFAInput{
+		MFAToken: challenge.MFAToken,
+		Code:     strings.ToUpper(strings.ReplaceAll(recovery.Codes[0], "-", "")),
+	}
+	_, err = s.authService.SignInMFA(context.Background(), recoveryInput)
+	s.NoError(err)
+	_, err = s.authService.SignInMFA(context.Background(), recoveryInput)
+	s.EqualError(err, errInvalidMFACode)
+
+	err = s.authService.DisableTOTP(context.Background(), userID, TOTPCodeInput{Code: recovery.Codes[1]})
+	s.Require().NoError(err)
+
+	tokens, err = s.authService.SignIn(context.Background(), SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+	s.False(tokens.MFARequired)
+	s.NotEmpty(tokens.AccessToken)
+}
+
+func (s *AuthServiceVerifySuite) TestLoginThrottle() {
+	admin, err := s.authService.SignUp(context.Background(), SignUpInput{
+		Username: "testadmin_" + uuid.New().String(),
+		Email:    "testadmin_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	})
+	s.Require().NoError(err)
+
+	cfg := newTestAuthConfig(s.privKeyPath, s.pubKeyPath)
+	cfg.LoginThrottle = LoginThrottleConfig{
+		FreeAttempts:     1,
+		IPFreeAttempts:   100,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 3,
+		LockoutDuration:  time.Hour,
+		Window:           24 * time.Hour,
+	}
+
+	repos := newTestRepository(s.pool)
+	s.Require().NoError(repos.Role.Assign(context.Background(), admin, RoleAdmin))
+
+	svc, err := NewAuthService(repos, s.mailer, cfg)
+	s.Require().NoError(err)
+
+	now := time.Now()
+	svc.(*authService).now = func() time.Time { return now }
+
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+	userID, err := svc.SignUp(context.Background(), input)
+	s.Require().NoError(err)
+
+	ip := "198.51.100." + strconv.Itoa(int(now.UnixNano()%250))
+	wrong := SignInInput{Email: input.Email, Password: "wrong-password", IP: ip}
+	right := SignInInput{Email: input.Email, Password: input.Password, IP: ip}
+
+	_, err = svc.SignIn(context.Background(), wrong)
+	s.EqualError(err, errInvalidPassword)
+
+	_, err = svc.SignIn(context.Background(), wrong)
+	s.EqualError(err, errInvalidPassword)
+
+	// The second failure is past the free attempts, so even the right password has to wait.
+	var throttled *ThrottledError
+	_, err = svc.SignIn(context.Background(), right)
+	s.Require().ErrorAs(err, &throttled)
+	s.Equal(time.Minute, throttled.RetryAfter)
+
+	now = now.Add(time.Minute)
+	_, err = svc.SignIn(context.Background(), wrong)
+	s.EqualError(err, errInvalidPassword)
+
+	// The third failure locks the account.
+	_, err = svc.SignIn(context.Background(), right)
+	s.Require().ErrorAs(err, &throttled)
+	s.Equal(time.Hour, throttled.RetryAfter)
+
+	err = svc.UnlockAccount(context.Background(), userID, userID)
+	s.EqualError(err, "forbidden")
+
+	err = svc.UnlockAccount(context.Background(), admin, userID)
+	s.Require().NoError(err)
+
+	tokens, err := svc.SignIn(context.Background(), right)
+	s.Require().NoError(err)
+	s.NotEmpty(tokens.AccessToken)
+
+	err = svc.UnlockAccount(context.Background(), admin, uuid.New())
+	s.EqualError(err, "user not found")
+}
+
+func (s *AuthServiceVerifySuite) TestPasswordRehash() {
+	ctx := context.Background()
+	users := postgres.NewUserRepository(s.pool)
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+
+	userID, err := s.authService.SignUp(ctx, input)
+	s.Require().NoError(err)
+
+	user, err := users.GetByID(ctx, userID)
+	s.Require().NoError(err)
+	s.True(strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+
+	// Accounts created before argon2id have bcrypt hashes.
+	legacy, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.MinCost)
+	s.Require().NoError(err)
+	s.Require().NoError(users.UpdatePassword(ctx, userID, string(legacy)))
+
+	_, err = s.authService.SignIn(ctx, SignInInput{Email: input.Email, Password: "wrong-password"})
+	s.EqualError(err, errInvalidPassword)
+
+	user, err = users.GetByID(ctx, userID)
+	s.Require().NoError(err)
+	s.Equal(string(legacy), user.PasswordHash, "a failed sign in must not rehash")
+
+	_, err = s.authService.SignIn(ctx, SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+
+	user, err = users.GetByID(ctx, userID)
+	s.Require().NoError(err)
+	s.True(strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+
+	// Stronger parameters are picked up the same way.
+	cfg := newTestAuthConfig(s.privKeyPath, s.pubKeyPath)
+	cfg.PasswordHasher, err = hasher.New(hasher.Argon2id, hasher.Argon2idParams{Memory: 32 * 1024}, bcrypt.DefaultCost)
+	s.Require().NoError(err)
+
+	svc, err := NewAuthService(newTestRepository(s.pool), s.mailer, cfg)
+	s.Require().NoError(err)
+
+	_, err = svc.SignIn(ctx, SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+
+	user, err = users.GetByID(ctx, userID)
+	s.Require().NoError(err)
+	s.Contains(user.PasswordHash, "$m=32768,")
+}
+
+func (s *AuthServiceVerifySuite) TestMagicLink() {
+	ctx := context.Background()
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+
+	userID, err := s.authService.SignUp(ctx, input)
+	s.Require().NoError(err)
+
+	sent := len(s.mailer.Sent())
+	unknown := MagicLinkInput{Email: "nobody_" + uuid.New().String() + "@example.com"}
+	s.Require().NoError(s.authService.SendMagicLink(ctx, unknown))
+	s.Len(s.mailer.Sent(), sent)
+
+	s.Require().NoError(s.authService.SendMagicLink(ctx, MagicLinkInput{Email: input.Email}))
+	first, ok := s.mailer.Last(input.Email)
+	s.Require().True(ok)
+
+	// A newer link replaces the one sent before it.
+	s.Require().NoError(s.authService.SendMagicLink(ctx, MagicLinkInput{Email: input.Email}))
+	msg, ok := s.mailer.Last(input.Email)
+	s.Require().True(ok)
+
+	_, err = s.authService.ConsumeMagicLink(ctx, ConsumeMagicLinkInput{Token: tokenFromMessage(first.Body)})
+	s.EqualError(err, errInvalidMagicLink)
+
+	tokens, err := s.authService.ConsumeMagicLink(ctx, ConsumeMagicLinkInput{Token: tokenFromMessage(msg.Body)})
+	s.Require().NoError(err)
+	s.NotEmpty(tokens.AccessToken)
+
+	claims, err := s.authService.ParseToken(ctx, tokens.AccessToken)
+	s.Require().NoError(err)
+	s.Equal(userID, claims.UserID)
+
+	// Opening the link verified the address.
+	user, err := postgres.NewUserRepository(s.pool).GetByID(ctx, userID)
+	s.Require().NoError(err)
+	s.NotNil(user.EmailVerifiedAt)
+
+	_, err = s.authService.ConsumeMagicLink(ctx, ConsumeMagicLinkInput{Token: tokenFromMessage(msg.Body)})
+	s.EqualError(err, errInvalidMagicLink)
+
+	_, err = s.authService.ConsumeMagicLink(ctx, ConsumeMagicLinkInput{Token: "not-a-token"})
+	s.EqualError(err, errInvalidMagicLink)
+
+	// The third request uses up the limit.
+	s.Require().NoError(s.authService.SendMagicLink(ctx, MagicLinkInput{Email: input.Email}))
+
+	var throttled *ThrottledError
+	err = s.authService.SendMagicLink(ctx, MagicLinkInput{Email: input.Email})
+	s.Require().ErrorAs(err, &throttled)
+	s.Positive(throttled.RetryAfter)
+}
+
+func (s *AuthServiceVerifySuite) TestPersonalAccessTokens() {
+	userID, err := s.authService.SignUp(context.Background(), SignUpInput{
+		Username: "testpat_" + uuid.New().String(),
+		Email:    "testpat_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	})
+	s.Require().NoError(err)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = s.authService.CreatePersonalAccessToken(context.Background(), userID, CreatePersonalAccessTokenInput{
+		Name:      "expired",
+		Scopes:    []string{ScopePostsRead},
+		ExpiresAt: &past,
+	})
+	s.EqualError(err, errInvalidExpiry)
+
+	created, err := s.authService.CreatePersonalAccessToken(context.Background(), userID, CreatePersonalAccessTokenInput{
+		Name:   "bot",
+		Scopes: []string{ScopePostsRead, ScopePostsWrite, ScopePostsRead},
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{ScopePostsRead, ScopePostsWrite}, created.Scopes)
+	s.Equal(created.Token[len(created.Token)-4:], created.Hint)
+
+	claims, err := s.authService.ParseToken(context.Background(), created.Token)
+	s.Require().NoError(err)
+	s.Equal(userID, claims.UserID)
+	s.True(claims.IsPersonalAccessToken())
+	s.True(claims.HasScope(ScopePostsWrite))
+	s.False(claims.HasScope(ScopeProfileRead))
+
+	tokens, err := s.authService.ListPersonalAccessTokens(context.Background(), userID)
+	s.Require().NoError(err)
+	s.Require().Len(tokens, 1)
+	s.NotNil(tokens[0].LastUsedAt)
+
+	err = s.authService.RevokePersonalAccessToken(context.Background(), uuid.New(), created.ID)
+	s.EqualError(err, errTokenNotFound)
+
+	err = s.authService.RevokePersonalAccessToken(context.Background(), userID, created.ID)
+	s.Require().NoError(err)
+
+	_, err = s.authService.ParseToken(context.Background(), created.Token)
+	s.EqualError(err, errInvalidAccessToken)
+
+	_, err = s.authService.ParseToken(context.Background(), PersonalAccessTokenPrefix+"unknown")
+	s.EqualError(err, errInvalidAccessToken)
+}
+
+func writeTestKey(dir, kid string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	return os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600)
+}
+
+func (s *AuthServiceVerifySuite) TestKeyRotation() {
+	dir := s.T().TempDir()
+	s.Require().NoError(writeTestKey(dir, "2024-01"))
+
+	keys, err := keyring.Load(dir, keyring.RS256)
+	s.Require().NoError(err)
+
+	cfg := newTestAuthConfig(s.privKeyPath, s.pubKeyPath)
+	cfg.Keys = keys
+
+	svc, err := NewAuthService(newTestRepository(s.pool), s.mailer, cfg)
+	s.Require().NoError(err)
+
+	input := SignUpInput{
+		Username: "testuser_" + uuid.New().String(),
+		Email:    "test_" + uuid.New().String() + "@example.com",
+		Password: "password123",
+	}
+	_, err = svc.SignUp(context.Background(), input)
+	s.Require().NoError(err)
+
+	before, err := svc.SignIn(context.Background(), SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+
+	s.Require().NoError(writeTestKey(dir, "2024-02"))
+	s.Require().NoError(keys.Reload())
+
+	after, err := svc.SignIn(context.Background(), SignInInput{Email: input.Email, Password: input.Password})
+	s.Require().NoError(err)
+
+	for _, token := range []string{before.AccessToken, after.AccessToken} {
+		_, err := svc.ParseToken(context.Background(), token)
+		s.NoError(err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(after.AccessToken, &AuthClaim{})
+	s.Require().NoError(err)
+	s.Equal("2024-02", parsed.Header["kid"])
+
+	s.Len(svc.JWKS().Keys, 2)
+
+	// Once the old key is gone, tokens it signed are no longer accepted.
+	s.Require().NoError(os.Remove(filepath.Join(dir, "2024-01.pem")))
+	s.Require().NoError(keys.Reload())
+
+	_, err = svc.ParseToken(context.Background(), before.AccessToken)
+	s.Error(err)
+}
+
 func TestAuthServiceVerifySuite(t *testing.T) {
 	suite.Run(t, new(AuthServiceVerifySuite))
 }
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+)
+
+const (
+	errCannotBlockSelf = "cannot block yourself"
+	errCannotMuteSelf  = "cannot mute yourself"
+)
+
+type blockService struct {
+	repo repository.BlockRepository
+}
+
+func NewBlockService(repos *repository.Repository) BlockService {
+	return &blockService{
+		repo: repos.Block,
+	}
+}
+
+// Block stops the two users from following each other or seeing each other's posts and profiles.
+// Existing follows and follow requests between them are dropped.
+func (s *blockService) Block(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	if blockerID == blockedID {
+		return errors.New(errCannotBlockSelf)
+	}
+
+	return s.repo.Block(ctx, blockerID, blockedID)
+}
+
+func (s *blockService) Unblock(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	if blockerID == blockedID {
+		return errors.New(errCannotBlockSelf)
+	}
+
+	return s.repo.Unblock(ctx, blockerID, blockedID)
+}
+
+// Mute hides the posts of mutedID from the feeds of muterID. Nothing changes for mutedID.
+func (s *blockService) Mute(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	if muterID == mutedID {
+		return errors.New(errCannotMuteSelf)
+	}
+
+	return s.repo.Mute(ctx, muterID, mutedID)
+}
+
+func (s *blockService) Unmute(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	if muterID == mutedID {
+		return errors.New(errCannotMuteSelf)
+	}
+
+	return s.repo.Unmute(ctx, muterID, mutedID)
+}
+
+func (s *blockService) ListBlocked(ctx context.Context, userID uuid.UUID, page PageInput) (*RestrictedPage, error) {
+	return s.list(ctx, userID, page, s.repo.ListBlocked)
+}
+
+func (s *blockService) ListMuted(ctx context.Context, userID uuid.UUID, page PageInput) (*RestrictedPage, error) {
+	return s.list(ctx, userID, page, s.repo.ListMuted)
+}
+
+func (s *blockService) list(
+	ctx context.Context,
+	userID uuid.UUID,
+	page PageInput,
+	list func(context.Context, uuid.UUID, *entity.Cursor, int) ([]entity.RestrictedUser, error),
+) (*RestrictedPage, error) {
+	after, err := page.after()
+	if err != nil {
+		return nil, err
+	}
+
+	// One more than asked for tells whether there is a next page.
+	size := page.size()
+	users, err := list(ctx, userID, after, size+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RestrictedPage{Users: users}
+	if len(users) > size {
+		result.Users = users[:size]
+		last := result.Users[size-1]
+		result.NextCursor = encodeCursor(last.Since, last.ID)
+	}
+
+	return result, nil
+}
+
+// blockedBetween reports whether either user has blocked the other. Anonymous viewers are never blocked.
+func blockedBetween(ctx context.Context, repo repository.BlockRepository, viewerID, userID uuid.UUID) (bool, error) {
+	if viewerID == uuid.Nil || viewerID == userID {
+		return false, nil
+	}
+
+	return repo.IsBlocked(ctx, viewerID, userID)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/defskela/SocialNetwork/internal/config"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+)
+
+type BlockServiceSuite struct {
+	suite.Suite
+	pool          *pgxpool.Pool
+	blockService  BlockService
+	followService FollowService
+	userService   UserService
+	postService   PostService
+	userRepo      repository.UserRepository
+	followRepo    repository.FollowRepository
+}
+
+func (s *BlockServiceSuite) SetupSuite() {
+	cfg := config.MustLoadPath("../../configs/local.yaml")
+	cfg.Postgres.Host = "localhost"
+
+	var err error
+	s.pool, err = postgresql.NewClient(context.Background(), 3, &cfg.Postgres)
+	s.Require().NoError(err)
+}
+
+func (s *BlockServiceSuite) TearDownSuite() {
+	if s.pool != nil {
+		s.pool.Close()
+	}
+}
+
+func (s *BlockServiceSuite) SetupTest() {
+	repos := newTestRepository(s.pool)
+	s.userRepo = repos.User
+	s.followRepo = repos.Follow
+	s.blockService = NewBlockService(repos)
+	s.followService = NewFollowService(repos)
+	s.userService = NewUserService(repos)
+	s.postService = NewPostService(repos, PostConfig{})
+}
+
+func (s *BlockServiceSuite) TestBlock() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "block_tester_", 3)
+	alice, bob, carol := users[0].ID, users[1].ID, users[2].ID
+
+	for _, pair := range [][2]uuid.UUID{{alice, bob}, {bob, alice}, {carol, alice}} {
+		_, err := s.followService.Follow(ctx, pair[0], pair[1])
+		s.Require().NoError(err)
+	}
+
+	postID, err := s.postService.Create(ctx, alice, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.blockService.Block(ctx, alice, bob))
+	s.Require().NoError(s.blockService.Block(ctx, alice, bob))
+
+	// The follows between them are gone in both directions, and only those.
+	profile, err := s.userRepo.GetPublicProfile(ctx, alice)
+	s.Require().NoError(err)
+	s.Equal(1, profile.FollowersCount)
+	s.Equal(0, profile.FollowingCount)
+
+	// The block works both ways.
+	for _, pair := range [][2]uuid.UUID{{alice, bob}, {bob, alice}} {
+		viewer, user := pair[0], pair[1]
+
+		_, err = s.followService.Follow(ctx, viewer, user)
+		s.EqualError(err, errUserNotFound)
+
+		_, err = s.userService.GetPublicProfile(ctx, viewer, user)
+		s.EqualError(err, errUserNotFound)
+
+		_, err = s.followService.ListFollowers(ctx, viewer, user, PageInput{})
+		s.EqualError(err, errUserNotFound)
+	}
+
+	_, err = s.postService.GetByID(ctx, bob, postID)
+	s.EqualError(err, errPostNotFound)
+
+	// Nobody else is affected.
+	_, err = s.postService.GetByID(ctx, carol, postID)
+	s.NoError(err)
+	_, err = s.userService.GetPublicProfile(ctx, uuid.Nil, alice)
+	s.NoError(err)
+
+	// Others still list alice among carol's follows, but bob does not see her there.
+	page, err := s.followService.ListFollowing(ctx, uuid.Nil, carol, PageInput{})
+	s.Require().NoError(err)
+	s.Require().Len(page.Users, 1)
+	s.Equal(alice, page.Users[0].ID)
+
+	page, err = s.followService.ListFollowing(ctx, bob, carol, PageInput{})
+	s.Require().NoError(err)
+	s.Empty(page.Users)
+
+	blocked, err := s.blockService.ListBlocked(ctx, alice, PageInput{})
+	s.Require().NoError(err)
+	s.Require().Len(blocked.Users, 1)
+	s.Equal(bob, blocked.Users[0].ID)
+
+	s.Require().NoError(s.blockService.Unblock(ctx, alice, bob))
+
+	_, err = s.postService.GetByID(ctx, bob, postID)
+	s.NoError(err)
+	_, err = s.followService.Follow(ctx, bob, alice)
+	s.NoError(err)
+
+	s.EqualError(s.blockService.Block(ctx, alice, alice), errCannotBlockSelf)
+	s.EqualError(s.blockService.Block(ctx, alice, uuid.New()), errUserNotFound)
+}
+
+// A follow racing a block must never survive it: either the follow fails or the block removes it.
+func (s *BlockServiceSuite) TestBlockRacingFollow() {
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		users := createTestUsers(s.T(), s.userRepo, "block_tester_", 2)
+		alice, bob := users[0].ID, users[1].ID
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.NoError(s.blockService.Block(ctx, alice, bob))
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := s.followService.Follow(ctx, bob, alice); err != nil {
+				s.EqualError(err, errUserNotFound)
+			}
+		}()
+		wg.Wait()
+
+		following, err := s.followRepo.IsFollowing(ctx, bob, alice)
+		s.Require().NoError(err)
+		s.False(following)
+	}
+}
+
+func (s *BlockServiceSuite) TestAcceptRequestAfterBlock() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "block_tester_", 2)
+	alice, bob := users[0], users[1]
+
+	alice.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, alice))
+
+	status, err := s.followService.Follow(ctx, bob.ID, alice.ID)
+	s.Require().NoError(err)
+	s.Equal(FollowStatusRequested, status)
+
+	s.Require().NoError(s.blockService.Block(ctx, bob.ID, alice.ID))
+
+	s.EqualError(s.followService.AcceptFollowRequest(ctx, alice.ID, bob.ID), errFollowRequestNotFound)
+	_, err = s.followService.Follow(ctx, bob.ID, alice.ID)
+	s.EqualError(err, errUserNotFound)
+}
+
+func (s *BlockServiceSuite) TestMute() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "block_tester_", 3)
+	alice, bob, carol := users[0].ID, users[1].ID, users[2].ID
+
+	s.Require().NoError(s.blockService.Mute(ctx, alice, bob))
+	s.Require().NoError(s.blockService.Mute(ctx, alice, bob))
+	s.Require().NoError(s.blockService.Mute(ctx, alice, carol))
+
+	page, err := s.blockService.ListMuted(ctx, alice, PageInput{Limit: 1})
+	s.Require().NoError(err)
+	s.Require().Len(page.Users, 1)
+	s.Equal(carol, page.Users[0].ID)
+	s.NotEmpty(page.NextCursor)
+
+	page, err = s.blockService.ListMuted(ctx, alice, PageInput{Cursor: page.NextCursor, Limit: 1})
+	s.Require().NoError(err)
+	s.Require().Len(page.Users, 1)
+	s.Equal(bob, page.Users[0].ID)
+	s.Empty(page.NextCursor)
+
+	// Muting is one-sided and does not block anything.
+	_, err = s.followService.Follow(ctx, bob, alice)
+	s.NoError(err)
+	_, err = s.userService.GetPublicProfile(ctx, bob, alice)
+	s.NoError(err)
+
+	s.Require().NoError(s.blockService.Unmute(ctx, alice, bob))
+	page, err = s.blockService.ListMuted(ctx, alice, PageInput{})
+	s.Require().NoError(err)
+	s.Len(page.Users, 1)
+
+	s.EqualError(s.blockService.Mute(ctx, alice, alice), errCannotMuteSelf)
+	s.EqualError(s.blockService.Mute(ctx, alice, uuid.New()), errUserNotFound)
+}
+
+func TestBlockService(t *testing.T) {
+	suite.Run(t, new(BlockServiceSuite))
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
+)
+
+const errInvalidVerificationToken = "invalid verification token"
+
+func (s *authService) VerifyEmail(ctx context.Context, input VerifyEmailInput) error {
+	claims, userID, err := s.parseActionToken(input.Token, purposeEmailVerification)
+	if err != nil {
+		return errors.New(errInvalidVerificationToken)
+	}
+
+	if err := s.userRepo.MarkEmailVerified(ctx, userID, claims.Email); err != nil {
+		if err.Error() == "user not found" {
+			return errors.New(errInvalidVerificationToken)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ResendVerification sends a fresh verification email. It succeeds silently for unknown
+// or already verified addresses, and when the email cannot be sent, so that it cannot be
+// used to probe for registered emails.
+func (s *authService) ResendVerification(ctx context.Context, input ResendVerificationInput) error {
+	user, err := s.userRepo.GetByEmail(ctx, input.Email)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	s.trySendVerificationEmail(ctx, user)
+	return nil
+}
+
+func (s *authService) sendVerificationEmail(ctx context.Context, user *entity.User) error {
+	token, err := s.signActionToken(purposeEmailVerification, user.ID, user.Email, s.emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.publicURL, url.QueryEscape(token))
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+				"The link expires in %s. If you did not sign up, you can ignore this message.\n",
+			user.Username, link, s.emailVerificationTTL,
+		),
+	})
+}
+
+// trySendVerificationEmail logs mail delivery problems instead of failing the request. After sign-up
+// the account already exists and the user can ask for a resend, and a resend must not tell apart
+// addresses that reached the mailer.
+func (s *authService) trySendVerificationEmail(ctx context.Context, user *entity.User) {
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("failed to send verification email to user %s: %v", user.ID, err)
+	}
+}
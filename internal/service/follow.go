@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+)
+
+const (
+	errCannotFollowSelf      = "cannot follow yourself"
+	errFollowRequestNotFound = "follow request not found"
+	errUserBlocked           = "user is blocked"
+)
+
+// What a follow ends up as.
+const (
+	FollowStatusFollowing = "following"
+	FollowStatusRequested = "requested"
+)
+
+type followService struct {
+	repo      repository.FollowRepository
+	userRepo  repository.UserRepository
+	blockRepo repository.BlockRepository
+	access    *accessPolicy
+}
+
+func NewFollowService(repos *repository.Repository) FollowService {
+	return &followService{
+		repo:      repos.Follow,
+		userRepo:  repos.User,
+		blockRepo: repos.Block,
+		access:    newAccessPolicy(repos.Role),
+	}
+}
+
+// Follow makes followerID follow followeeID, or asks to if followeeID is a private account,
+// and returns FollowStatusFollowing or FollowStatusRequested. Following someone twice is not an error.
+func (s *followService) Follow(ctx context.Context, followerID, followeeID uuid.UUID) (string, error) {
+	if followerID == followeeID {
+		return "", errors.New(errCannotFollowSelf)
+	}
+
+	followee, err := s.userRepo.GetByID(ctx, followeeID)
+	if err != nil {
+		return "", err
+	}
+
+	status, err := s.follow(ctx, followerID, followee)
+	if err != nil && err.Error() == errUserBlocked {
+		// Users who blocked each other do not see each other at all.
+		return "", errors.New(errUserNotFound)
+	}
+
+	return status, err
+}
+
+// follow leaves checking for blocks to the repository, which does it in the transaction
+// that adds the follow, so that a block made meanwhile cannot be missed.
+func (s *followService) follow(ctx context.Context, followerID uuid.UUID, followee *entity.User) (string, error) {
+	if !followee.IsPrivate {
+		if _, err := s.repo.Follow(ctx, followerID, followee.ID); err != nil {
+			return "", err
+		}
+		return FollowStatusFollowing, nil
+	}
+
+	following, err := s.repo.IsFollowing(ctx, followerID, followee.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if following {
+		return FollowStatusFollowing, nil
+	}
+
+	if err := s.repo.Request(ctx, followerID, followee.ID); err != nil {
+		return "", err
+	}
+
+	return FollowStatusRequested, nil
+}
+
+// Unfollow stops followerID from following followeeID and withdraws a pending request.
+// Unfollowing someone who was not followed is not an error.
+func (s *followService) Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	if followerID == followeeID {
+		return errors.New(errCannotFollowSelf)
+	}
+
+	if _, err := s.repo.Unfollow(ctx, followerID, followeeID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteRequest(ctx, followerID, followeeID); err != nil && err.Error() != errFollowRequestNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// ListFollowRequests returns the pending requests to follow userID.
+func (s *followService) ListFollowRequests(ctx context.Context, userID uuid.UUID, page PageInput) (*FollowPage, error) {
+	// Blocking drops requests, so there are no blocked users to leave out.
+	requests := func(
+		ctx context.Context,
+		_, userID uuid.UUID,
+		after *entity.Cursor,
+		limit int,
+	) ([]entity.FollowUser, error) {
+		return s.repo.ListRequests(ctx, userID, after, limit)
+	}
+
+	return s.list(ctx, userID, userID, page, requests)
+}
+
+// AcceptFollowRequest lets requesterID follow userID. Blocking drops requests, so a request
+// between users who blocked each other is reported as not found.
+func (s *followService) AcceptFollowRequest(ctx context.Context, userID, requesterID uuid.UUID) error {
+	err := s.repo.AcceptRequest(ctx, requesterID, userID)
+	if err != nil && err.Error() == errUserBlocked {
+		return errors.New(errFollowRequestNotFound)
+	}
+
+	return err
+}
+
+func (s *followService) RejectFollowRequest(ctx context.Context, userID, requesterID uuid.UUID) error {
+	return s.repo.DeleteRequest(ctx, requesterID, userID)
+}
+
+func (s *followService) ListFollowers(
+	ctx context.Context,
+	viewerID, userID uuid.UUID,
+	page PageInput,
+) (*FollowPage, error) {
+	return s.list(ctx, viewerID, userID, page, s.repo.ListFollowers)
+}
+
+func (s *followService) ListFollowing(
+	ctx context.Context,
+	viewerID, userID uuid.UUID,
+	page PageInput,
+) (*FollowPage, error) {
+	return s.list(ctx, viewerID, userID, page, s.repo.ListFollowing)
+}
+
+// list returns a page of the follows of userID if viewerID may see them, as checkOwner tells.
+// Users viewerID blocked or was blocked by are left out of the page.
+func (s *followService) list(
+	ctx context.Context,
+	viewerID, userID uuid.UUID,
+	page PageInput,
+	list func(context.Context, uuid.UUID, uuid.UUID, *entity.Cursor, int) ([]entity.FollowUser, error),
+) (*FollowPage, error) {
+	after, err := page.after()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(ctx, viewerID, userID); err != nil {
+		return nil, err
+	}
+
+	// One more than asked for tells whether there is a next page.
+	size := page.size()
+	users, err := list(ctx, viewerID, userID, after, size+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FollowPage{Users: users}
+	if len(users) > size {
+		result.Users = users[:size]
+		last := result.Users[size-1]
+		result.NextCursor = encodeCursor(last.FollowedAt, last.ID)
+	}
+
+	return result, nil
+}
+
+// checkOwner tells whether viewerID may see whom userID follows and is followed by. Like the posts of
+// a private account, its follows are only shown to its approved followers and moderators. Everyone else,
+// and anyone on either side of a block with userID, is told that the user does not exist.
+func (s *followService) checkOwner(ctx context.Context, viewerID, userID uuid.UUID) error {
+	owner, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if viewerID == userID {
+		return nil
+	}
+
+	blocked, err := blockedBetween(ctx, s.blockRepo, viewerID, userID)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return errors.New(errUserNotFound)
+	}
+
+	if !owner.IsPrivate {
+		return nil
+	}
+
+	following, err := s.repo.IsFollowing(ctx, viewerID, userID)
+	if err != nil {
+		return err
+	}
+
+	if following {
+		return nil
+	}
+
+	moderator, err := s.access.can(ctx, viewerID, PermissionPostsModerate)
+	if err != nil {
+		return err
+	}
+
+	if !moderator {
+		return errors.New(errUserNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/defskela/SocialNetwork/internal/config"
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+)
+
+type FollowServiceSuite struct {
+	suite.Suite
+	pool          *pgxpool.Pool
+	followService FollowService
+	userRepo      repository.UserRepository
+	roleRepo      repository.RoleRepository
+}
+
+func (s *FollowServiceSuite) SetupSuite() {
+	cfg := config.MustLoadPath("../../configs/local.yaml")
+	cfg.Postgres.Host = "localhost"
+
+	var err error
+	s.pool, err = postgresql.NewClient(context.Background(), 3, &cfg.Postgres)
+	s.Require().NoError(err)
+}
+
+func (s *FollowServiceSuite) TearDownSuite() {
+	if s.pool != nil {
+		s.pool.Close()
+	}
+}
+
+func (s *FollowServiceSuite) SetupTest() {
+	repos := newTestRepository(s.pool)
+	s.userRepo = repos.User
+	s.roleRepo = repos.Role
+	s.followService = NewFollowService(repos)
+}
+
+func (s *FollowServiceSuite) counts(id uuid.UUID) (followers, following int) {
+	profile, err := s.userRepo.GetPublicProfile(context.Background(), id)
+	s.Require().NoError(err)
+	return profile.FollowersCount, profile.FollowingCount
+}
+
+func (s *FollowServiceSuite) TestFollow() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "follow_tester_", 2)
+	alice, bob := users[0].ID, users[1].ID
+
+	status, err := s.followService.Follow(ctx, alice, bob)
+	s.Require().NoError(err)
+	s.Equal(FollowStatusFollowing, status)
+	_, err = s.followService.Follow(ctx, alice, bob)
+	s.Require().NoError(err)
+
+	followers, following := s.counts(bob)
+	s.Equal(1, followers)
+	s.Equal(0, following)
+
+	followers, following = s.counts(alice)
+	s.Equal(0, followers)
+	s.Equal(1, following)
+
+	page, err := s.followService.ListFollowers(ctx, uuid.Nil, bob, PageInput{})
+	s.Require().NoError(err)
+	s.Require().Len(page.Users, 1)
+	s.Equal(alice, page.Users[0].ID)
+	s.Empty(page.NextCursor)
+
+	page, err = s.followService.ListFollowing(ctx, uuid.Nil, alice, PageInput{})
+	s.Require().NoError(err)
+	s.Require().Len(page.Users, 1)
+	s.Equal(bob, page.Users[0].ID)
+
+	s.Require().NoError(s.followService.Unfollow(ctx, alice, bob))
+	s.Require().NoError(s.followService.Unfollow(ctx, alice, bob))
+
+	followers, _ = s.counts(bob)
+	s.Equal(0, followers)
+	_, following = s.counts(alice)
+	s.Equal(0, following)
+
+	_, err = s.followService.Follow(ctx, alice, alice)
+	s.EqualError(err, errCannotFollowSelf)
+	_, err = s.followService.Follow(ctx, alice, uuid.New())
+	s.EqualError(err, "user not found")
+
+	_, err = s.followService.ListFollowers(ctx, uuid.Nil, uuid.New(), PageInput{})
+	s.EqualError(err, "user not found")
+
+	_, err = s.followService.ListFollowers(ctx, uuid.Nil, bob, PageInput{Cursor: "garbage"})
+	s.EqualError(err, errInvalidCursor)
+}
+
+func (s *FollowServiceSuite) TestPagination() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "follow_tester_", 6)
+	target := users[0].ID
+
+	for _, user := range users[1:] {
+		_, err := s.followService.Follow(ctx, user.ID, target)
+		s.Require().NoError(err)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	page := PageInput{Limit: 2}
+	for pages := 0; ; pages++ {
+		s.Require().Less(pages, 3)
+
+		result, err := s.followService.ListFollowers(ctx, uuid.Nil, target, page)
+		s.Require().NoError(err)
+
+		for _, user := range result.Users {
+			s.False(seen[user.ID], "%s is listed twice", user.ID)
+			seen[user.ID] = true
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+
+	s.Len(seen, 5)
+}
+
+// Follows and unfollows racing each other, including users following one another, must not deadlock or
+// leave the counts out of step with the follows.
+func (s *FollowServiceSuite) TestConcurrentFollows() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "follow_tester_", 8)
+
+	var wg sync.WaitGroup
+	for _, a := range users {
+		for _, b := range users {
+			if a.ID == b.ID {
+				continue
+			}
+			wg.Add(1)
+			go func(a, b uuid.UUID) {
+				defer wg.Done()
+				_, err := s.followService.Follow(ctx, a, b)
+				s.NoError(err)
+			}(a.ID, b.ID)
+		}
+	}
+	wg.Wait()
+
+	for _, user := range users {
+		followers, following := s.counts(user.ID)
+		s.Equal(len(users)-1, followers)
+		s.Equal(len(users)-1, following)
+	}
+
+	for _, a := range users[1:] {
+		wg.Add(2)
+		go func(a uuid.UUID) {
+			defer wg.Done()
+			s.NoError(s.followService.Unfollow(ctx, a, users[0].ID))
+		}(a.ID)
+		go func(a uuid.UUID) {
+			defer wg.Done()
+			s.NoError(s.followService.Unfollow(ctx, users[0].ID, a))
+		}(a.ID)
+	}
+	wg.Wait()
+
+	followers, following := s.counts(users[0].ID)
+	s.Equal(0, followers)
+	s.Equal(0, following)
+
+	followers, following = s.counts(users[1].ID)
+	s.Equal(len(users)-2, followers)
+	s.Equal(len(users)-2, following)
+}
+
+func (s *FollowServiceSuite) TestPrivateAccount() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "follow_tester_", 4)
+	owner, alice, bob, moderator := users[0], users[1], users[2], users[3]
+
+	owner.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, owner))
+
+	for _, user := range []*entity.User{alice, bob} {
+		status, err := s.followService.Follow(ctx, user.ID, owner.ID)
+		s.Require().NoError(err)
+		s.Equal(FollowStatusRequested, status)
+	}
+
+	// Asking twice leaves a single request.
+	_, err := s.followService.Follow(ctx, alice.ID, owner.ID)
+	s.Require().NoError(err)
+
+	followers, _ := s.counts(owner.ID)
+	s.Equal(0, followers)
+
+	requests, err := s.followService.ListFollowRequests(ctx, owner.ID, PageInput{})
+	s.Require().NoError(err)
+	s.Require().Len(requests.Users, 2)
+	s.Equal(bob.ID, requests.Users[0].ID)
+	s.Equal(alice.ID, requests.Users[1].ID)
+
+	s.Require().NoError(s.followService.AcceptFollowRequest(ctx, owner.ID, alice.ID))
+	s.Require().NoError(s.followService.RejectFollowRequest(ctx, owner.ID, bob.ID))
+
+	s.EqualError(s.followService.AcceptFollowRequest(ctx, owner.ID, bob.ID), errFollowRequestNotFound)
+	s.EqualError(s.followService.RejectFollowRequest(ctx, owner.ID, alice.ID), errFollowRequestNotFound)
+
+	requests, err = s.followService.ListFollowRequests(ctx, owner.ID, PageInput{})
+	s.Require().NoError(err)
+	s.Empty(requests.Users)
+
+	followers, _ = s.counts(owner.ID)
+	s.Equal(1, followers)
+	_, following := s.counts(alice.ID)
+	s.Equal(1, following)
+
+	// An approved follower stays one when following again.
+	status, err := s.followService.Follow(ctx, alice.ID, owner.ID)
+	s.Require().NoError(err)
+	s.Equal(FollowStatusFollowing, status)
+
+	// Unfollowing withdraws a pending request.
+	_, err = s.followService.Follow(ctx, bob.ID, owner.ID)
+	s.Require().NoError(err)
+	s.Require().NoError(s.followService.Unfollow(ctx, bob.ID, owner.ID))
+	s.EqualError(s.followService.AcceptFollowRequest(ctx, owner.ID, bob.ID), errFollowRequestNotFound)
+
+	// Only the owner, approved followers and moderators see whom a private account follows and is followed by.
+	s.Require().NoError(s.roleRepo.Assign(ctx, moderator.ID, RoleModerator))
+	for _, viewer := range []uuid.UUID{owner.ID, alice.ID, moderator.ID} {
+		page, err := s.followService.ListFollowers(ctx, viewer, owner.ID, PageInput{})
+		s.Require().NoError(err)
+		s.Require().Len(page.Users, 1)
+		s.Equal(alice.ID, page.Users[0].ID)
+
+		_, err = s.followService.ListFollowing(ctx, viewer, owner.ID, PageInput{})
+		s.NoError(err)
+	}
+
+	for _, viewer := range []uuid.UUID{bob.ID, uuid.Nil} {
+		_, err = s.followService.ListFollowers(ctx, viewer, owner.ID, PageInput{})
+		s.EqualError(err, errUserNotFound)
+		_, err = s.followService.ListFollowing(ctx, viewer, owner.ID, PageInput{})
+		s.EqualError(err, errUserNotFound)
+	}
+}
+
+func TestFollowService(t *testing.T) {
+	suite.Run(t, new(FollowServiceSuite))
+}
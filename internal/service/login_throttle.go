@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const errTooManyAttempts = "too many login attempts"
+
+const (
+	throttleScopeAccount = "account"
+	throttleScopeIP      = "ip"
+)
+
+type LoginThrottleConfig struct {
+	// FreeAttempts is how many failures an account may have before each further attempt is delayed.
+	FreeAttempts int
+	// IPFreeAttempts is the same for a client IP, which is shared by every account tried from it.
+	IPFreeAttempts int
+	// BaseDelay is the first delay. It doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold is the number of failures that locks the account for LockoutDuration.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Window is how long a failure counts for. Counters start over after a quiet Window.
+	Window time.Duration
+}
+
+// ThrottledError is returned when sign in is refused because of earlier failures.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return errTooManyAttempts
+}
+
+// delay returns how long to refuse attempts after the given number of failures.
+func (c LoginThrottleConfig) delay(failures, freeAttempts int) time.Duration {
+	if failures <= freeAttempts || c.BaseDelay <= 0 {
+		return 0
+	}
+
+	d := c.BaseDelay
+	for i := freeAttempts + 1; i < failures; i++ {
+		d *= 2
+		if d >= c.MaxDelay {
+			return c.MaxDelay
+		}
+	}
+
+	return min(d, c.MaxDelay)
+}
+
+// UnlockAccount clears the failed attempts of a user, lifting a lockout early.
+func (s *authService) UnlockAccount(ctx context.Context, actorID, userID uuid.UUID) error {
+	if err := s.requirePermission(ctx, actorID, PermissionUsersUnlock); err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.throttleRepo.Reset(ctx, throttleScopeAccount, userID.String())
+}
+
+// checkThrottle refuses the attempt while the account or the IP is blocked.
+// Either key may be empty when it is not known yet.
+func (s *authService) checkThrottle(ctx context.Context, accountKey, ip string) error {
+	var retryAfter time.Duration
+
+	for _, k := range throttleKeys(accountKey, ip) {
+		throttle, err := s.throttleRepo.Get(ctx, k.scope, k.key)
+		if err != nil {
+			if err.Error() == "login throttle not found" {
+				continue
+			}
+			return err
+		}
+
+		if throttle.BlockedUntil != nil {
+			retryAfter = max(retryAfter, throttle.BlockedUntil.Sub(s.now()))
+		}
+	}
+
+	if retryAfter > 0 {
+		return &ThrottledError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// recordFailure counts a failed attempt and blocks further attempts as the failures pile up.
+func (s *authService) recordFailure(ctx context.Context, accountKey, ip string) error {
+	now := s.now()
+
+	for _, k := range throttleKeys(accountKey, ip) {
+		failures, err := s.throttleRepo.RecordFailure(ctx, k.scope, k.key, now, now.Add(-s.throttle.Window))
+		if err != nil {
+			return err
+		}
+
+		var d time.Duration
+		if k.scope == throttleScopeAccount {
+			d = s.throttle.delay(failures, s.throttle.FreeAttempts)
+			if s.throttle.LockoutThreshold > 0 && failures >= s.throttle.LockoutThreshold {
+				d = max(d, s.throttle.LockoutDuration)
+			}
+		} else {
+			d = s.throttle.delay(failures, s.throttle.IPFreeAttempts)
+		}
+
+		if d > 0 {
+			if err := s.throttleRepo.Block(ctx, k.scope, k.key, now.Add(d)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resetThrottle forgets the failures of an account after a successful sign in.
+// The IP counter is left alone: one good password must not let an attacker go on guessing others.
+func (s *authService) resetThrottle(ctx context.Context, accountKey string) error {
+	return s.throttleRepo.Reset(ctx, throttleScopeAccount, accountKey)
+}
+
+type throttleKey struct {
+	scope string
+	key   string
+}
+
+func throttleKeys(accountKey, ip string) []throttleKey {
+	keys := make([]throttleKey, 0, 2)
+	if accountKey != "" {
+		keys = append(keys, throttleKey{scope: throttleScopeAccount, key: accountKey})
+	}
+	if ip != "" {
+		keys = append(keys, throttleKey{scope: throttleScopeIP, key: ip})
+	}
+	return keys
+}
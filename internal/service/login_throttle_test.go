@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottleDelay(t *testing.T) {
+	cfg := LoginThrottleConfig{
+		BaseDelay: time.Second,
+		MaxDelay:  10 * time.Second,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, cfg.delay(tt.failures, 3), "failures %d", tt.failures)
+	}
+
+	assert.Zero(t, LoginThrottleConfig{}.delay(10, 0))
+}
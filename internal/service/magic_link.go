@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
+)
+
+const (
+	purposeMagicLink       = "magic_link"
+	errInvalidMagicLink    = "invalid magic link"
+	throttleScopeMagicLink = "magic_link"
+)
+
+type MagicLinkConfig struct {
+	// TTL is how long an emailed link can be used.
+	TTL time.Duration
+	// MaxRequests is how many links may be sent to one address within Window.
+	// Further requests are refused until a quiet Window has passed.
+	MaxRequests int
+	Window      time.Duration
+}
+
+// SendMagicLink emails a single-use sign in link. As with ForgotPassword, unknown addresses are
+// accepted silently, and they count towards the limit like any other.
+func (s *authService) SendMagicLink(ctx context.Context, input MagicLinkInput) error {
+	if err := s.throttleMagicLink(ctx, input.Email); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, input.Email)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil
+		}
+		return err
+	}
+
+	claims := s.newActionClaim(purposeMagicLink, user.ID, user.Email, s.magicLink.TTL)
+	linkID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.magicLinkRepo.Create(ctx, &entity.MagicLink{
+		ID:        linkID,
+		UserID:    user.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return err
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/magic-link?token=%s", s.publicURL, url.QueryEscape(token))
+
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign in link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to sign in:\n\n%s\n\n"+
+				"The link expires in %s and can be used once. If you did not ask for it, you can ignore this message.\n",
+			user.Username, link, s.magicLink.TTL,
+		),
+	}); err != nil {
+		// Reporting the failure would tell the caller that the address exists.
+		log.Printf("failed to send magic link to user %s: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ConsumeMagicLink signs in with an emailed link. The link stands in for the password only,
+// so users with two-factor authentication still get an MFA challenge.
+func (s *authService) ConsumeMagicLink(ctx context.Context, input ConsumeMagicLinkInput) (Tokens, error) {
+	claims, userID, err := s.parseActionToken(input.Token, purposeMagicLink)
+	if err != nil {
+		return Tokens{}, errors.New(errInvalidMagicLink)
+	}
+
+	linkID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return Tokens{}, errors.New(errInvalidMagicLink)
+	}
+
+	if _, err := s.magicLinkRepo.Consume(ctx, linkID); err != nil {
+		if err.Error() == "magic link not found" {
+			return Tokens{}, errors.New(errInvalidMagicLink)
+		}
+		return Tokens{}, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	// A link sent to an address the user has since moved away from proves nothing.
+	if user.Email != claims.Email {
+		return Tokens{}, errors.New(errInvalidMagicLink)
+	}
+
+	// Opening the link proves the user owns the address.
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+			return Tokens{}, err
+		}
+	}
+
+	enrollment, err := s.confirmedTOTP(ctx, user.ID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if enrollment != nil {
+		return s.mfaChallenge(user.ID)
+	}
+
+	return s.startSession(ctx, user.ID, input.Device, input.IP)
+}
+
+// throttleMagicLink counts the links requested for an address and refuses the request once there were
+// MaxRequests within Window. This keeps the endpoint from being used to flood someone's inbox.
+func (s *authService) throttleMagicLink(ctx context.Context, email string) error {
+	if s.magicLink.MaxRequests <= 0 {
+		return nil
+	}
+
+	key := strings.ToLower(strings.TrimSpace(email))
+	now := s.now()
+
+	throttle, err := s.throttleRepo.Get(ctx, throttleScopeMagicLink, key)
+	if err != nil && err.Error() != "login throttle not found" {
+		return err
+	}
+
+	if throttle != nil && throttle.BlockedUntil != nil && throttle.BlockedUntil.After(now) {
+		return &ThrottledError{RetryAfter: throttle.BlockedUntil.Sub(now)}
+	}
+
+	sent, err := s.throttleRepo.RecordFailure(ctx, throttleScopeMagicLink, key, now, now.Add(-s.magicLink.Window))
+	if err != nil {
+		return err
+	}
+
+	if sent >= s.magicLink.MaxRequests {
+		return s.throttleRepo.Block(ctx, throttleScopeMagicLink, key, now.Add(s.magicLink.Window))
+	}
+
+	return nil
+}
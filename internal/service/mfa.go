@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/pkg/totp"
+)
+
+const (
+	errMFAAlreadyEnabled = "mfa already enabled"
+	errMFANotEnabled     = "mfa not enabled"
+	errInvalidMFACode    = "invalid mfa code"
+	errInvalidMFAToken   = "invalid mfa token"
+)
+
+const (
+	purposeMFA = "mfa"
+	// totpSkew is how many 30 second steps either side of now a code is accepted for,
+	// to tolerate clock drift between the server and the authenticator app.
+	totpSkew          = 1
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 5
+)
+
+// EnrollTOTP generates a new secret for the user. It does not protect sign in until it is confirmed with a code.
+func (s *authService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (TOTPEnrollment, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	if err := s.mfaRepo.SaveTOTP(ctx, &entity.TOTP{UserID: userID, Secret: secret}); err != nil {
+		if err.Error() == "totp already confirmed" {
+			return TOTPEnrollment{}, errors.New(errMFAAlreadyEnabled)
+		}
+		return TOTPEnrollment{}, err
+	}
+
+	return TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP turns on two-factor authentication once the user proves their app generates valid codes.
+// The returned recovery codes are shown only once; just their hashes are stored.
+func (s *authService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, input TOTPCodeInput) (RecoveryCodes, error) {
+	enrollment, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if err.Error() == "totp not found" {
+			return RecoveryCodes{}, errors.New(errMFANotEnabled)
+		}
+		return RecoveryCodes{}, err
+	}
+
+	if enrollment.ConfirmedAt != nil {
+		return RecoveryCodes{}, errors.New(errMFAAlreadyEnabled)
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, input.Code, s.now(), totpSkew)
+	if !ok {
+		return RecoveryCodes{}, errors.New(errInvalidMFACode)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return RecoveryCodes{}, err
+	}
+
+	if err := s.mfaRepo.ConfirmTOTP(ctx, userID, step, hashes); err != nil {
+		if err.Error() == "totp not found" {
+			return RecoveryCodes{}, errors.New(errMFAAlreadyEnabled)
+		}
+		return RecoveryCodes{}, err
+	}
+
+	return RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableTOTP turns two-factor authentication off. It takes a current code or an unused recovery code.
+func (s *authService) DisableTOTP(ctx context.Context, userID uuid.UUID, input TOTPCodeInput) error {
+	enrollment, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if enrollment == nil {
+		return errors.New(errMFANotEnabled)
+	}
+
+	if err := s.verifyMFACode(ctx, enrollment, input.Code); err != nil {
+		return err
+	}
+
+	return s.mfaRepo.DeleteTOTP(ctx, userID)
+}
+
+// SignInMFA completes a sign in that SignIn answered with an MFA challenge.
+func (s *authService) SignInMFA(ctx context.Context, input SignInMFAInput) (Tokens, error) {
+	_, userID, err := s.parseActionToken(input.MFAToken, purposeMFA)
+	if err != nil {
+		return Tokens{}, errors.New(errInvalidMFAToken)
+	}
+
+	enrollment, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if enrollment == nil {
+		return Tokens{}, errors.New(errInvalidMFAToken)
+	}
+
+	accountKey := userID.String()
+	if err := s.checkThrottle(ctx, accountKey, input.IP); err != nil {
+		return Tokens{}, err
+	}
+
+	if err := s.verifyMFACode(ctx, enrollment, input.Code); err != nil {
+		if err.Error() == errInvalidMFACode {
+			return Tokens{}, s.failSignIn(ctx, accountKey, input.IP, err)
+		}
+		return Tokens{}, err
+	}
+
+	if err := s.resetThrottle(ctx, accountKey); err != nil {
+		return Tokens{}, err
+	}
+
+	return s.startSession(ctx, userID, input.Device, input.IP)
+}
+
+// mfaChallenge returns the response to a correct password when the user has two-factor authentication on.
+func (s *authService) mfaChallenge(userID uuid.UUID) (Tokens, error) {
+	token, err := s.signActionToken(purposeMFA, userID, "", s.mfaChallengeTTL)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{MFARequired: true, MFAToken: token}, nil
+}
+
+// confirmedTOTP returns the user's enrollment, or nil if two-factor authentication is off.
+func (s *authService) confirmedTOTP(ctx context.Context, userID uuid.UUID) (*entity.TOTP, error) {
+	enrollment, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if err.Error() == "totp not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if enrollment.ConfirmedAt == nil {
+		return nil, nil
+	}
+
+	return enrollment, nil
+}
+
+// verifyMFACode accepts either a code from the authenticator app or a recovery code.
+// Both can be used only once.
+func (s *authService) verifyMFACode(ctx context.Context, enrollment *entity.TOTP, code string) error {
+	code = strings.TrimSpace(code)
+
+	if step, ok := totp.Validate(enrollment.Secret, code, s.now(), totpSkew); ok {
+		if err := s.mfaRepo.UseTOTPStep(ctx, enrollment.UserID, step); err != nil {
+			if err.Error() == "totp code already used" {
+				return errors.New(errInvalidMFACode)
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := s.mfaRepo.UseRecoveryCode(ctx, enrollment.UserID, hashToken(normalizeRecoveryCode(code))); err != nil {
+		if err.Error() == "recovery code not found" {
+			return errors.New(errInvalidMFACode)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		code := hex.EncodeToString(raw)
+		codes = append(codes, code[:len(code)/2]+"-"+code[len(code)/2:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes without the dash or in upper case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
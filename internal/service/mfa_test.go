@@ -0,0 +1,25 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	require.Len(t, hashes, recoveryCodeCount)
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		assert.Len(t, code, 2*recoveryCodeBytes+1)
+		assert.Equal(t, hashes[i], hashToken(normalizeRecoveryCode(code)))
+		assert.Equal(t, hashes[i], hashToken(normalizeRecoveryCode(strings.ToUpper(code))))
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+}
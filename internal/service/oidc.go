@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/pkg/oidc"
+)
+
+const (
+	errOIDCProviderNotFound = "oidc provider not found"
+	errInvalidOIDCState     = "invalid oidc state"
+	errOIDCSignInFailed     = "oidc sign in failed"
+	errOIDCEmailNotVerified = "email not verified by provider"
+	errOIDCEmailConflict    = "email is registered to another account"
+)
+
+const (
+	oidcUsernameMaxBase = 20
+	oidcUsernameSuffix  = 3
+)
+
+// OIDCAuthURL starts a sign in with an external provider. The state, nonce and PKCE verifier
+// are kept until the provider redirects back to SignInOIDC.
+func (s *authService) OIDCAuthURL(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return "", errors.New(errOIDCProviderNotFound)
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.oidcRepo.SaveState(ctx, &entity.OIDCState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    s.now().Add(s.oidcStateTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+// SignInOIDC completes a sign in with an external provider. The identity is looked up by the provider's
+// subject. A new identity is linked to the user with the same email, or a new user is created,
+// but only when the provider has verified the email.
+func (s *authService) SignInOIDC(ctx context.Context, input OIDCCallbackInput) (Tokens, error) {
+	provider, ok := s.oidcProviders[input.Provider]
+	if !ok {
+		return Tokens{}, errors.New(errOIDCProviderNotFound)
+	}
+
+	state, err := s.oidcRepo.ConsumeState(ctx, hashToken(input.State))
+	if err != nil {
+		if err.Error() == "oidc state not found" {
+			return Tokens{}, errors.New(errInvalidOIDCState)
+		}
+		return Tokens{}, err
+	}
+
+	if state.Provider != input.Provider || !state.ExpiresAt.After(s.now()) {
+		return Tokens{}, errors.New(errInvalidOIDCState)
+	}
+
+	token, err := provider.Exchange(ctx, input.Code, state.CodeVerifier)
+	if err != nil {
+		return Tokens{}, errors.New(errOIDCSignInFailed)
+	}
+
+	claims, err := provider.Verify(ctx, token.IDToken, state.Nonce)
+	if err != nil {
+		return Tokens{}, errors.New(errOIDCSignInFailed)
+	}
+
+	userID, err := s.oidcUser(ctx, input.Provider, claims)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	enrollment, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if enrollment != nil {
+		return s.mfaChallenge(userID)
+	}
+
+	return s.startSession(ctx, userID, input.Device, input.IP)
+}
+
+// oidcUser returns the user an external identity belongs to, linking or creating one on first sign in.
+func (s *authService) oidcUser(ctx context.Context, provider string, claims *oidc.Claims) (uuid.UUID, error) {
+	identity, err := s.oidcRepo.GetIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		return identity.UserID, nil
+	}
+	if err.Error() != "identity not found" {
+		return uuid.Nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return uuid.Nil, errors.New(errOIDCEmailNotVerified)
+	}
+
+	identity = &entity.UserIdentity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	if err != nil && err.Error() != "user not found" {
+		return uuid.Nil, err
+	}
+
+	if user != nil {
+		// Someone may have registered the address without owning it, and would then
+		// know the password of the account the real owner signs in to.
+		if user.EmailVerifiedAt == nil {
+			return uuid.Nil, errors.New(errOIDCEmailConflict)
+		}
+
+		identity.UserID = user.ID
+		if err := s.oidcRepo.LinkIdentity(ctx, identity); err != nil {
+			return uuid.Nil, err
+		}
+		return user.ID, nil
+	}
+
+	username, err := oidcUsername(claims)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	verifiedAt := s.now()
+	user = &entity.User{
+		Username: username,
+		Email:    claims.Email,
+		// No password until the user sets one through a password reset.
+		PasswordHash:    "",
+		EmailVerifiedAt: &verifiedAt,
+	}
+
+	if err := s.oidcRepo.CreateUserWithIdentity(ctx, user, identity); err != nil {
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
+}
+
+// oidcUsername derives a username from the profile claims, with a random suffix so it does not collide.
+func oidcUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		}
+		if b.Len() == oidcUsernameMaxBase {
+			break
+		}
+	}
+
+	if b.Len() < 3 {
+		b.Reset()
+		b.WriteString("user")
+	}
+
+	suffix := make([]byte, oidcUsernameSuffix)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	return b.String() + "_" + hex.EncodeToString(suffix), nil
+}
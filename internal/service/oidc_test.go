@@ -0,0 +1,33 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defskela/SocialNetwork/pkg/oidc"
+)
+
+func TestOIDCUsername(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims oidc.Claims
+		prefix string
+	}{
+		{"Preferred username", oidc.Claims{PreferredUsername: "Jane.Doe", Email: "x@example.com"}, "janedoe_"},
+		{"Email", oidc.Claims{Email: "john_smith@example.com"}, "john_smith_"},
+		{"Too short", oidc.Claims{Email: "j@example.com"}, "user_"},
+		{"Too long", oidc.Claims{PreferredUsername: "abcdefghijklmnopqrstuvwxyz"}, "abcdefghijklmnopqrst_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, err := oidcUsername(&tt.claims)
+			require.NoError(t, err)
+			assert.Regexp(t, "^"+regexp.QuoteMeta(tt.prefix)+"[0-9a-f]{6}$", username)
+			assert.LessOrEqual(t, len(username), 32)
+		})
+	}
+}
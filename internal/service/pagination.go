@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+)
+
+const errInvalidCursor = "invalid cursor"
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PageInput asks for the page of a list that starts after Cursor, which is empty for the first page.
+type PageInput struct {
+	Cursor string
+	Limit  int
+}
+
+// size returns the page size, defaulting to DefaultPageSize and capped at MaxPageSize.
+func (p PageInput) size() int {
+	if p.Limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(p.Limit, MaxPageSize)
+}
+
+// after decodes the cursor. The first page has none.
+func (p PageInput) after() (*entity.Cursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, errors.New(errInvalidCursor)
+	}
+
+	micros, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, errors.New(errInvalidCursor)
+	}
+
+	unix, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, errors.New(errInvalidCursor)
+	}
+
+	cursorID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.New(errInvalidCursor)
+	}
+
+	return &entity.Cursor{CreatedAt: time.UnixMicro(unix), ID: cursorID}, nil
+}
+
+// encodeCursor returns the opaque cursor of an item. Postgres keeps microseconds, so nothing is lost.
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatInt(createdAt.UnixMicro(), 10) + "." + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
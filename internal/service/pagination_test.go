@@ -0,0 +1,39 @@
+package service
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageInput(t *testing.T) {
+	assert.Equal(t, DefaultPageSize, PageInput{}.size())
+	assert.Equal(t, 5, PageInput{Limit: 5}.size())
+	assert.Equal(t, MaxPageSize, PageInput{Limit: 1000}.size())
+
+	after, err := PageInput{}.after()
+	require.NoError(t, err)
+	assert.Nil(t, after)
+
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	id := uuid.New()
+
+	after, err = PageInput{Cursor: encodeCursor(createdAt, id)}.after()
+	require.NoError(t, err)
+	assert.True(t, createdAt.Equal(after.CreatedAt))
+	assert.Equal(t, id, after.ID)
+
+	for _, cursor := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("no-separator")),
+		base64.RawURLEncoding.EncodeToString([]byte("abc." + id.String())),
+		base64.RawURLEncoding.EncodeToString([]byte("1709296200000000.not-a-uuid")),
+	} {
+		_, err := PageInput{Cursor: cursor}.after()
+		assert.EqualError(t, err, errInvalidCursor, cursor)
+	}
+}
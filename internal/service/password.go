@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
+)
+
+const (
+	errInvalidResetToken = "invalid reset token"
+	errInvalidPassword   = "invalid password"
+)
+
+// ChangePassword replaces the password of a signed-in user after checking the current one.
+// Every other session is revoked; the session that made the change stays signed in.
+func (s *authService) ChangePassword(
+	ctx context.Context,
+	userID, currentSessionID uuid.UUID,
+	input ChangePasswordInput,
+) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.hasher.Verify(user.PasswordHash, input.CurrentPassword); err != nil {
+		return errors.New(errInvalidPassword)
+	}
+
+	if err := s.setPassword(ctx, user, input.NewPassword); err != nil {
+		return err
+	}
+
+	return s.revokeSessionsExcept(ctx, userID, currentSessionID)
+}
+
+// ForgotPassword emails a one-time reset link. Unknown addresses are accepted silently so that
+// the endpoint cannot be used to find out which emails are registered.
+func (s *authService) ForgotPassword(ctx context.Context, input ForgotPasswordInput) error {
+	user, err := s.userRepo.GetByEmail(ctx, input.Email)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil
+		}
+		return err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := s.resetRepo.Create(ctx, &entity.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.passwordResetTTL),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.publicURL, url.QueryEscape(token))
+
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\n"+
+				"The link expires in %s and can be used once. If it was not you, you can ignore this message.\n",
+			user.Username, link, s.passwordResetTTL,
+		),
+	}); err != nil {
+		// Reporting the failure would tell the caller that the address exists.
+		log.Printf("failed to send password reset email to user %s: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere.
+// The token is only used up once the new password has passed the policy, so that a rejected
+// password can be replaced without asking for another email.
+func (s *authService) ResetPassword(ctx context.Context, input ResetPasswordInput) error {
+	tokenHash := hashToken(input.Token)
+	token, err := s.resetRepo.GetActive(ctx, tokenHash)
+	if err != nil {
+		if err.Error() == "reset token not found" {
+			return errors.New(errInvalidResetToken)
+		}
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.passwordPolicy.Validate(input.Password, user); err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hasher.Hash(input.Password)
+	if err != nil {
+		return err
+	}
+
+	// Only one of concurrent resets with the same token gets past Consume.
+	if _, err := s.resetRepo.Consume(ctx, tokenHash); err != nil {
+		if err.Error() == "reset token not found" {
+			return errors.New(errInvalidResetToken)
+		}
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		return err
+	}
+
+	return s.LogoutAll(ctx, token.UserID)
+}
+
+// setPassword checks password against the policy and stores its hash.
+func (s *authService) setPassword(ctx context.Context, user *entity.User, password string) error {
+	if err := s.passwordPolicy.Validate(password, user); err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.UpdatePassword(ctx, user.ID, passwordHash)
+}
+
+// rehashPassword replaces a stored hash that was made with an older algorithm or weaker parameters,
+// while the plain password is at hand after a successful sign in. A failure only delays the upgrade
+// to the next sign in, so it is logged rather than returned.
+func (s *authService) rehashPassword(ctx context.Context, user *entity.User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	passwordHash, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.userRepo.ReplacePasswordHash(ctx, user.ID, user.PasswordHash, passwordHash)
+	}
+	if err != nil {
+		log.Printf("failed to rehash password of user %s: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+)
+
+const errWeakPassword = "weak password"
+
+type PasswordPolicyConfig struct {
+	MinLength int
+	// BreachedListPath points to a file with one known-breached password per line.
+	// Lines starting with # are ignored. Leave empty to skip the check.
+	BreachedListPath string
+}
+
+type passwordPolicy struct {
+	minLength int
+	breached  map[string]struct{}
+}
+
+func newPasswordPolicy(cfg PasswordPolicyConfig) (*passwordPolicy, error) {
+	policy := &passwordPolicy{
+		minLength: cfg.MinLength,
+		breached:  make(map[string]struct{}),
+	}
+
+	if cfg.BreachedListPath == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(cfg.BreachedListPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.breached[strings.ToLower(line)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read breached password list: %w", err)
+	}
+
+	return policy, nil
+}
+
+// Validate checks a new password for user against the policy.
+func (p *passwordPolicy) Validate(password string, user *entity.User) error {
+	if len([]rune(password)) < p.minLength {
+		return fmt.Errorf("%s: must be at least %d characters long", errWeakPassword, p.minLength)
+	}
+
+	lower := strings.ToLower(password)
+
+	if _, ok := p.breached[lower]; ok {
+		return fmt.Errorf("%s: it appears in a list of breached passwords", errWeakPassword)
+	}
+
+	email := strings.ToLower(user.Email)
+	localPart, _, _ := strings.Cut(email, "@")
+	if lower == strings.ToLower(user.Username) || lower == email || lower == localPart {
+		return errors.New(errWeakPassword + ": must not match the username or email")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	listPath := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(listPath, []byte("# common passwords\nqwertyuiop\n\nPassword123\n"), 0o600))
+
+	policy, err := newPasswordPolicy(PasswordPolicyConfig{MinLength: 8, BreachedListPath: listPath})
+	require.NoError(t, err)
+
+	user := &entity.User{Username: "johndoe_1", Email: "john.doe@example.com"}
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{name: "OK", password: "correct horse battery", valid: true},
+		{name: "Too short", password: "short"},
+		{name: "Breached", password: "QWERTYUIOP"},
+		{name: "Breached mixed case in list", password: "password123"},
+		{name: "Matches username", password: "JohnDoe_1"},
+		{name: "Matches email", password: "john.doe@example.com"},
+		{name: "Matches email local part", password: "john.doe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, user)
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), errWeakPassword))
+		})
+	}
+}
+
+func TestPasswordPolicy_MissingList(t *testing.T) {
+	_, err := newPasswordPolicy(PasswordPolicyConfig{
+		MinLength:        8,
+		BreachedListPath: filepath.Join(t.TempDir(), "missing.txt"),
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+)
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+const (
+	PermissionPostsModerate = "posts:moderate"
+	PermissionUsersUnlock   = "users:unlock"
+	PermissionRolesManage   = "roles:manage"
+)
+
+// accessPolicy decides what a user may do from the roles stored in the database.
+// It is consulted on every decision rather than trusting the permissions in the
+// access token, so that taking a role away takes effect at once. Callers using a
+// personal access token get no permissions at all, whatever their roles.
+type accessPolicy struct {
+	roleRepo repository.RoleRepository
+}
+
+func newAccessPolicy(roleRepo repository.RoleRepository) *accessPolicy {
+	return &accessPolicy{roleRepo: roleRepo}
+}
+
+type callerKey struct{}
+
+// WithCaller records the claims a request was made with, so that the access policy can tell
+// personal access tokens from sessions.
+func WithCaller(ctx context.Context, claims *AuthClaim) context.Context {
+	return context.WithValue(ctx, callerKey{}, claims)
+}
+
+func callerFrom(ctx context.Context) *AuthClaim {
+	claims, _ := ctx.Value(callerKey{}).(*AuthClaim)
+	return claims
+}
+
+// grants returns the names of the roles of a user and the permissions they add up to.
+func (p *accessPolicy) grants(ctx context.Context, userID uuid.UUID) (roles, permissions []string, err error) {
+	assigned, err := p.roleRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, role := range assigned {
+		roles = append(roles, role.Name)
+		permissions = append(permissions, role.Permissions...)
+	}
+	slices.Sort(permissions)
+
+	return roles, slices.Compact(permissions), nil
+}
+
+func (p *accessPolicy) can(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
+	if caller := callerFrom(ctx); caller != nil && caller.IsPersonalAccessToken() {
+		return false, nil
+	}
+
+	_, permissions, err := p.grants(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(permissions, permission), nil
+}
+
+// canModifyPost allows authors to edit and delete their own posts and moderators to act on anyone's.
+func (p *accessPolicy) canModifyPost(ctx context.Context, userID uuid.UUID, post *entity.Post) (bool, error) {
+	if post.UserID == userID {
+		return true, nil
+	}
+
+	return p.can(ctx, userID, PermissionPostsModerate)
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+)
+
+type stubRoleRepository struct {
+	repository.RoleRepository
+	roles map[uuid.UUID][]entity.Role
+}
+
+func (r *stubRoleRepository) ListByUser(_ context.Context, userID uuid.UUID) ([]entity.Role, error) {
+	return r.roles[userID], nil
+}
+
+func TestAccessPolicy(t *testing.T) {
+	author, moderator, admin, stranger := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	policy := newAccessPolicy(&stubRoleRepository{roles: map[uuid.UUID][]entity.Role{
+		moderator: {{Name: RoleModerator, Permissions: []string{PermissionPostsModerate}}},
+		admin: {
+			{Name: RoleAdmin, Permissions: []string{PermissionPostsModerate, PermissionRolesManage}},
+			{Name: RoleModerator, Permissions: []string{PermissionPostsModerate}},
+		},
+	}})
+	post := &entity.Post{UserID: author}
+
+	roles, permissions, err := policy.grants(context.Background(), admin)
+	require.NoError(t, err)
+	assert.Equal(t, []string{RoleAdmin, RoleModerator}, roles)
+	assert.Equal(t, []string{PermissionPostsModerate, PermissionRolesManage}, permissions)
+
+	for _, tt := range []struct {
+		name    string
+		userID  uuid.UUID
+		allowed bool
+	}{
+		{"author", author, true},
+		{"moderator", moderator, true},
+		{"admin", admin, true},
+		{"stranger", stranger, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := policy.canModifyPost(context.Background(), tt.userID, post)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, allowed)
+		})
+	}
+}
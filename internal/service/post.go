@@ -10,31 +10,118 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	errEmailNotVerified   = "email not verified"
+	errPostNotFound       = "post not found"
+	errPrivateAccount     = "account is private"
+	errInvalidTimeRange   = "until must be after since"
+	errReactionNotAllowed = "reaction not allowed"
+)
+
+// DefaultReactions are the emoji users may react with when PostConfig.Reactions is empty.
+var DefaultReactions = []string{"👍", "❤️", "😂", "😮", "😢", "😡"}
+
+type PostConfig struct {
+	// RequireVerifiedEmail blocks users who have not confirmed their email from posting.
+	RequireVerifiedEmail bool
+	// Timeline serves feeds from timelines written on post. Without it feeds are queried from the posts.
+	Timeline *TimelineWorker
+	// Reactions lists the emoji users may react to posts with.
+	Reactions []string
+}
+
 type postService struct {
-	repo repository.PostRepository
+	repo       repository.PostRepository
+	userRepo   repository.UserRepository
+	followRepo repository.FollowRepository
+	blockRepo  repository.BlockRepository
+	likeRepo   repository.LikeRepository
+	reactRepo  repository.ReactionRepository
+	access     *accessPolicy
+	reactions  map[string]bool
+	cfg        PostConfig
 }
 
-func NewPostService(repo repository.PostRepository) PostService {
+func NewPostService(repos *repository.Repository, cfg PostConfig) PostService {
+	if len(cfg.Reactions) == 0 {
+		cfg.Reactions = DefaultReactions
+	}
+
+	reactions := make(map[string]bool, len(cfg.Reactions))
+	for _, emoji := range cfg.Reactions {
+		reactions[emoji] = true
+	}
+
 	return &postService{
-		repo: repo,
+		repo:       repos.Post,
+		userRepo:   repos.User,
+		followRepo: repos.Follow,
+		blockRepo:  repos.Block,
+		likeRepo:   repos.Like,
+		reactRepo:  repos.Reaction,
+		access:     newAccessPolicy(repos.Role),
+		reactions:  reactions,
+		cfg:        cfg,
 	}
 }
 
 func (s *postService) Create(ctx context.Context, userID uuid.UUID, input CreatePostInput) (uuid.UUID, error) {
+	if s.cfg.RequireVerifiedEmail {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		if user.EmailVerifiedAt == nil {
+			return uuid.Nil, errors.New(errEmailNotVerified)
+		}
+	}
+
+	// Replying to a post needs seeing it.
+	if input.ReplyToID != nil {
+		if _, err := s.GetByID(ctx, userID, *input.ReplyToID); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
 	post := &entity.Post{
-		UserID:  userID,
-		Content: input.Content,
+		UserID:    userID,
+		Content:   input.Content,
+		ReplyToID: input.ReplyToID,
 	}
 
 	if err := s.repo.Create(ctx, post); err != nil {
 		return uuid.Nil, err
 	}
 
+	if s.cfg.Timeline != nil {
+		s.cfg.Timeline.Wake()
+	}
+
 	return post.ID, nil
 }
 
-func (s *postService) GetByID(ctx context.Context, id uuid.UUID) (*entity.Post, error) {
-	return s.repo.GetByID(ctx, id)
+// GetByID returns a post if viewerID may see it. Posts by private accounts are reported as not found
+// to everyone but the author, approved followers and moderators, and posts by users who blocked the
+// viewer, or whom the viewer blocked, to everyone but moderators.
+func (s *postService) GetByID(ctx context.Context, viewerID, id uuid.UUID) (*entity.Post, error) {
+	post, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkAuthor(ctx, viewerID, post.UserID); err != nil {
+		if err.Error() == errUserNotFound || err.Error() == errPrivateAccount {
+			return nil, errors.New(errPostNotFound)
+		}
+		return nil, err
+	}
+
+	if err := s.annotate(ctx, viewerID, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
 }
 
 func (s *postService) Update(
@@ -47,8 +134,8 @@ func (s *postService) Update(
 		return nil, err
 	}
 
-	if post.UserID != userID {
-		return nil, errors.New("forbidden")
+	if err := s.authorize(ctx, userID, post); err != nil {
+		return nil, err
 	}
 
 	post.Content = input.Content
@@ -57,6 +144,10 @@ func (s *postService) Update(
 		return nil, err
 	}
 
+	if err := s.annotate(ctx, userID, post); err != nil {
+		return nil, err
+	}
+
 	return post, nil
 }
 
@@ -66,9 +157,340 @@ func (s *postService) Delete(ctx context.Context, userID, postID uuid.UUID) erro
 		return err
 	}
 
-	if post.UserID != userID {
-		return errors.New("forbidden")
+	if err := s.authorize(ctx, userID, post); err != nil {
+		return err
 	}
 
 	return s.repo.Delete(ctx, postID)
 }
+
+// Feed returns the posts of the users userID follows, newest first, without those of muted users.
+func (s *postService) Feed(ctx context.Context, userID uuid.UUID, page PageInput) (*PostPage, error) {
+	after, err := page.after()
+	if err != nil {
+		return nil, err
+	}
+
+	list := s.repo.ListFeed
+	if s.cfg.Timeline != nil {
+		list = s.cfg.Timeline.list
+	}
+
+	// One more than asked for tells whether there is a next page.
+	size := page.size()
+	posts, err := list(ctx, userID, after, size+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := postPage(posts, size)
+	if err := s.annotate(ctx, userID, pointers(result.Posts)...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// postPage turns the up to size+1 posts fetched for a page into the page.
+func postPage(posts []entity.Post, size int) *PostPage {
+	result := &PostPage{Posts: posts}
+	if len(posts) > size {
+		result.Posts = posts[:size]
+		last := result.Posts[size-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return result
+}
+
+// ListByUser returns the posts of userID, newest first, if viewerID may see them. Users whose posts
+// viewerID may not see are reported as not found.
+func (s *postService) ListByUser(
+	ctx context.Context,
+	viewerID, userID uuid.UUID,
+	page PageInput,
+	filter PostFilter,
+) (*PostPage, error) {
+	after, err := page.after()
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Since != nil && filter.Until != nil && !filter.Until.After(*filter.Since) {
+		return nil, errors.New(errInvalidTimeRange)
+	}
+
+	if err := s.checkAuthor(ctx, viewerID, userID); err != nil {
+		// Like GetByID, do not tell a private account with posts from no account at all.
+		if err.Error() == errPrivateAccount {
+			return nil, errors.New(errUserNotFound)
+		}
+		return nil, err
+	}
+
+	// One more than asked for tells whether there is a next page.
+	size := page.size()
+	posts, err := s.repo.ListByUser(ctx, userID, after, filter.Since, filter.Until, filter.IncludeReplies, size+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := postPage(posts, size)
+	if err := s.annotate(ctx, viewerID, pointers(result.Posts)...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Like makes userID like a post they can see and returns how many likes it has.
+func (s *postService) Like(ctx context.Context, userID, postID uuid.UUID) (*LikeResult, error) {
+	if _, err := s.GetByID(ctx, userID, postID); err != nil {
+		return nil, err
+	}
+
+	count, err := s.likeRepo.Like(ctx, postID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LikeResult{LikeCount: count, LikedByMe: true}, nil
+}
+
+// Unlike takes back a like. It works on posts that have since been hidden from userID too,
+// but then fails with errPostNotFound like it does for posts that do not exist.
+func (s *postService) Unlike(ctx context.Context, userID, postID uuid.UUID) (*LikeResult, error) {
+	count, err := s.likeRepo.Unlike(ctx, postID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetByID(ctx, userID, postID); err != nil {
+		return nil, err
+	}
+
+	return &LikeResult{LikeCount: count}, nil
+}
+
+// ListLikes returns the users who liked a post viewerID can see, newest like first.
+func (s *postService) ListLikes(ctx context.Context, viewerID, postID uuid.UUID, page PageInput) (*LikePage, error) {
+	after, err := page.after()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetByID(ctx, viewerID, postID); err != nil {
+		return nil, err
+	}
+
+	// One more than asked for tells whether there is a next page.
+	size := page.size()
+	likers, err := s.likeRepo.ListLikers(ctx, viewerID, postID, after, size+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LikePage{Users: likers}
+	if len(likers) > size {
+		result.Users = likers[:size]
+		last := result.Users[size-1]
+		result.NextCursor = encodeCursor(last.LikedAt, last.ID)
+	}
+
+	return result, nil
+}
+
+// React adds a reaction from userID to a post they can see and returns the reactions the post has.
+func (s *postService) React(ctx context.Context, userID, postID uuid.UUID, emoji string) (*ReactionResult, error) {
+	if !s.reactions[emoji] {
+		return nil, errors.New(errReactionNotAllowed)
+	}
+
+	if _, err := s.GetByID(ctx, userID, postID); err != nil {
+		return nil, err
+	}
+
+	if err := s.reactRepo.Add(ctx, postID, userID, emoji); err != nil {
+		return nil, err
+	}
+
+	return s.reactionResult(ctx, userID, postID)
+}
+
+// Unreact takes back a reaction. Like Unlike it works on hidden posts, and on emoji that are
+// no longer allowed, so that users can always take back what they reacted with. Hidden posts
+// then fail with errPostNotFound like posts that do not exist, rather than show their reactions.
+func (s *postService) Unreact(ctx context.Context, userID, postID uuid.UUID, emoji string) (*ReactionResult, error) {
+	if err := s.reactRepo.Remove(ctx, postID, userID, emoji); err != nil {
+		return nil, err
+	}
+
+	post, err := s.GetByID(ctx, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReactionResult{Reactions: post.Reactions, MyReactions: post.MyReactions}, nil
+}
+
+func (s *postService) reactionResult(ctx context.Context, userID, postID uuid.UUID) (*ReactionResult, error) {
+	post := &entity.Post{ID: postID}
+	if err := s.markReactions(ctx, userID, []uuid.UUID{postID}, []*entity.Post{post}); err != nil {
+		return nil, err
+	}
+
+	return &ReactionResult{Reactions: post.Reactions, MyReactions: post.MyReactions}, nil
+}
+
+// annotate fills in what depends on viewerID rather than on the stored post: whether they liked it,
+// and the reactions on it along with their own.
+func (s *postService) annotate(ctx context.Context, viewerID uuid.UUID, posts ...*entity.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	if err := s.markLiked(ctx, viewerID, ids, posts); err != nil {
+		return err
+	}
+
+	return s.markReactions(ctx, viewerID, ids, posts)
+}
+
+// markLiked sets LikedByMe on the posts viewerID has liked.
+func (s *postService) markLiked(ctx context.Context, viewerID uuid.UUID, ids []uuid.UUID, posts []*entity.Post) error {
+	liked, err := s.likeRepo.LikedBy(ctx, viewerID, ids)
+	if err != nil {
+		return err
+	}
+
+	likedIDs := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		likedIDs[id] = true
+	}
+
+	for _, post := range posts {
+		post.LikedByMe = likedIDs[post.ID]
+	}
+
+	return nil
+}
+
+// markReactions sets Reactions and MyReactions on the posts. Both are empty rather than nil
+// for posts without reactions.
+func (s *postService) markReactions(
+	ctx context.Context,
+	viewerID uuid.UUID,
+	ids []uuid.UUID,
+	posts []*entity.Post,
+) error {
+	counts, err := s.reactRepo.Counts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	mine, err := s.reactRepo.ByUser(ctx, viewerID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Reactions = counts[post.ID]
+		if post.Reactions == nil {
+			post.Reactions = map[string]int{}
+		}
+
+		post.MyReactions = mine[post.ID]
+		if post.MyReactions == nil {
+			post.MyReactions = []string{}
+		}
+	}
+
+	return nil
+}
+
+func pointers(posts []entity.Post) []*entity.Post {
+	result := make([]*entity.Post, len(posts))
+	for i := range posts {
+		result[i] = &posts[i]
+	}
+	return result
+}
+
+// checkAuthor tells whether viewerID may see the posts of authorID. It fails with errUserNotFound if either
+// has blocked the other and with errPrivateAccount if the author is private and not followed by the viewer.
+// Moderators may see every post.
+func (s *postService) checkAuthor(ctx context.Context, viewerID, authorID uuid.UUID) error {
+	if viewerID == authorID {
+		return nil
+	}
+
+	author, err := s.userRepo.GetByID(ctx, authorID)
+	if err != nil {
+		return err
+	}
+
+	denied, err := s.denial(ctx, viewerID, author)
+	if err != nil {
+		return err
+	}
+
+	if denied == "" {
+		return nil
+	}
+
+	moderator, err := s.access.can(ctx, viewerID, PermissionPostsModerate)
+	if err != nil {
+		return err
+	}
+
+	if moderator {
+		return nil
+	}
+
+	return errors.New(denied)
+}
+
+// denial returns why viewerID may not see the posts of author, or nothing if they may.
+func (s *postService) denial(ctx context.Context, viewerID uuid.UUID, author *entity.User) (string, error) {
+	blocked, err := blockedBetween(ctx, s.blockRepo, viewerID, author.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if blocked {
+		return errUserNotFound, nil
+	}
+
+	if !author.IsPrivate {
+		return "", nil
+	}
+
+	following, err := s.followRepo.IsFollowing(ctx, viewerID, author.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if following {
+		return "", nil
+	}
+
+	return errPrivateAccount, nil
+}
+
+func (s *postService) authorize(ctx context.Context, userID uuid.UUID, post *entity.Post) error {
+	allowed, err := s.access.canModifyPost(ctx, userID, post)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return errors.New("forbidden")
+	}
+
+	return nil
+}
@@ -2,12 +2,12 @@ package service
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/defskela/SocialNetwork/internal/config"
 	"github.com/defskela/SocialNetwork/internal/entity"
 	"github.com/defskela/SocialNetwork/internal/repository"
-	"github.com/defskela/SocialNetwork/internal/repository/postgres"
 	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
 
 	"github.com/google/uuid"
@@ -20,6 +20,9 @@ type PostServiceSuite struct {
 	pool        *pgxpool.Pool
 	postService PostService
 	userRepo    repository.UserRepository
+	roleRepo    repository.RoleRepository
+	follows     FollowService
+	blocks      BlockService
 }
 
 func (s *PostServiceSuite) SetupSuite() {
@@ -38,9 +41,12 @@ func (s *PostServiceSuite) TearDownSuite() {
 }
 
 func (s *PostServiceSuite) SetupTest() {
-	s.userRepo = postgres.NewUserRepository(s.pool)
-	postRepo := postgres.NewPostRepository(s.pool)
-	s.postService = NewPostService(postRepo)
+	repos := newTestRepository(s.pool)
+	s.userRepo = repos.User
+	s.roleRepo = repos.Role
+	s.postService = NewPostService(repos, PostConfig{})
+	s.follows = NewFollowService(repos)
+	s.blocks = NewBlockService(repos)
 }
 
 func (s *PostServiceSuite) TestCRUD() {
@@ -59,7 +65,7 @@ func (s *PostServiceSuite) TestCRUD() {
 	s.Require().NoError(err)
 	s.NotEqual(uuid.Nil, id)
 
-	post, err := s.postService.GetByID(ctx, id)
+	post, err := s.postService.GetByID(ctx, user.ID, id)
 	s.Require().NoError(err)
 	s.Equal(input.Content, post.Content)
 	s.Equal(user.ID, post.UserID)
@@ -81,10 +87,555 @@ func (s *PostServiceSuite) TestCRUD() {
 	err = s.postService.Delete(ctx, user.ID, id)
 	s.Require().NoError(err)
 
-	_, err = s.postService.GetByID(ctx, id)
+	_, err = s.postService.GetByID(ctx, user.ID, id)
 	s.Error(err)
 }
 
+func (s *PostServiceSuite) TestCreate_RequireVerifiedEmail() {
+	ctx := context.Background()
+	postService := NewPostService(newTestRepository(s.pool), PostConfig{RequireVerifiedEmail: true})
+
+	uniqueName := "unverified_" + uuid.New().String()
+	user := &entity.User{
+		Username:     uniqueName,
+		Email:        uniqueName + "@example.com",
+		PasswordHash: "hash",
+	}
+	s.Require().NoError(s.userRepo.Create(ctx, user))
+
+	_, err := postService.Create(ctx, user.ID, CreatePostInput{Content: "Hello"})
+	s.Error(err)
+	s.Equal(errEmailNotVerified, err.Error())
+
+	s.Require().NoError(s.userRepo.MarkEmailVerified(ctx, user.ID, user.Email))
+
+	id, err := postService.Create(ctx, user.ID, CreatePostInput{Content: "Hello"})
+	s.NoError(err)
+	s.NotEqual(uuid.Nil, id)
+}
+
+func (s *PostServiceSuite) TestModeration() {
+	ctx := context.Background()
+
+	users := make([]*entity.User, 2)
+	for i := range users {
+		uniqueName := "moderation_" + uuid.New().String()
+		users[i] = &entity.User{
+			Username:     uniqueName,
+			Email:        uniqueName + "@example.com",
+			PasswordHash: "hash",
+		}
+		s.Require().NoError(s.userRepo.Create(ctx, users[i]))
+	}
+	author, moderator := users[0], users[1]
+
+	id, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	_, err = s.postService.Update(ctx, moderator.ID, id, UpdatePostInput{Content: "[removed]"})
+	s.EqualError(err, "forbidden")
+
+	s.Require().NoError(s.roleRepo.Assign(ctx, moderator.ID, RoleModerator))
+
+	post, err := s.postService.Update(ctx, moderator.ID, id, UpdatePostInput{Content: "[removed]"})
+	s.Require().NoError(err)
+	s.Equal(author.ID, post.UserID)
+
+	s.Require().NoError(s.roleRepo.Remove(ctx, moderator.ID, RoleModerator))
+
+	err = s.postService.Delete(ctx, moderator.ID, id)
+	s.EqualError(err, "forbidden")
+
+	// Personal access tokens carry no permissions, not even a moderator's.
+	s.Require().NoError(s.roleRepo.Assign(ctx, moderator.ID, RoleModerator))
+	patCtx := WithCaller(ctx, &AuthClaim{
+		UserID:        moderator.ID,
+		AccessTokenID: uuid.New(),
+		Scopes:        []string{ScopePostsRead, ScopePostsWrite},
+	})
+	_, err = s.postService.Update(patCtx, moderator.ID, id, UpdatePostInput{Content: "[removed]"})
+	s.EqualError(err, "forbidden")
+	s.EqualError(s.postService.Delete(patCtx, moderator.ID, id), "forbidden")
+
+	author.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, author))
+	_, err = s.postService.GetByID(patCtx, moderator.ID, id)
+	s.EqualError(err, errPostNotFound)
+	_, err = s.postService.GetByID(ctx, moderator.ID, id)
+	s.NoError(err)
+
+	s.Require().NoError(s.postService.Delete(ctx, moderator.ID, id))
+}
+
+func (s *PostServiceSuite) TestPrivateAccountVisibility() {
+	ctx := context.Background()
+
+	users := make([]*entity.User, 4)
+	for i := range users {
+		uniqueName := "visibility_" + uuid.New().String()
+		users[i] = &entity.User{
+			Username:     uniqueName,
+			Email:        uniqueName + "@example.com",
+			PasswordHash: "hash",
+		}
+		s.Require().NoError(s.userRepo.Create(ctx, users[i]))
+	}
+	author, follower, stranger, moderator := users[0], users[1], users[2], users[3]
+
+	id, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	_, err = s.postService.GetByID(ctx, stranger.ID, id)
+	s.Require().NoError(err)
+
+	author.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, author))
+
+	_, err = s.follows.Follow(ctx, follower.ID, author.ID)
+	s.Require().NoError(err)
+
+	// A pending request is not enough.
+	_, err = s.postService.GetByID(ctx, follower.ID, id)
+	s.EqualError(err, errPostNotFound)
+
+	s.Require().NoError(s.follows.AcceptFollowRequest(ctx, author.ID, follower.ID))
+
+	for _, viewer := range []*entity.User{author, follower} {
+		post, err := s.postService.GetByID(ctx, viewer.ID, id)
+		s.Require().NoError(err)
+		s.Equal(author.ID, post.UserID)
+	}
+
+	_, err = s.postService.GetByID(ctx, stranger.ID, id)
+	s.EqualError(err, errPostNotFound)
+
+	s.Require().NoError(s.roleRepo.Assign(ctx, moderator.ID, RoleModerator))
+	_, err = s.postService.GetByID(ctx, moderator.ID, id)
+	s.NoError(err)
+}
+
+func (s *PostServiceSuite) TestFeed() {
+	ctx := context.Background()
+
+	users := make([]*entity.User, 4)
+	for i := range users {
+		uniqueName := "feed_" + uuid.New().String()
+		users[i] = &entity.User{
+			Username:     uniqueName,
+			Email:        uniqueName + "@example.com",
+			PasswordHash: "hash",
+		}
+		s.Require().NoError(s.userRepo.Create(ctx, users[i]))
+	}
+	reader, alice, bob, stranger := users[0], users[1], users[2], users[3]
+
+	for _, user := range []*entity.User{alice, bob} {
+		_, err := s.follows.Follow(ctx, reader.ID, user.ID)
+		s.Require().NoError(err)
+	}
+
+	var want []uuid.UUID
+	for i := 0; i < 3; i++ {
+		for _, author := range []*entity.User{alice, bob, stranger, reader} {
+			id, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Hello"})
+			s.Require().NoError(err)
+			if author == alice || author == bob {
+				want = append([]uuid.UUID{id}, want...)
+			}
+		}
+	}
+
+	var got []uuid.UUID
+	page := PageInput{Limit: 4}
+	for pages := 0; ; pages++ {
+		s.Require().Less(pages, 2)
+
+		result, err := s.postService.Feed(ctx, reader.ID, page)
+		s.Require().NoError(err)
+
+		for _, post := range result.Posts {
+			got = append(got, post.ID)
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+	s.Equal(want, got)
+
+	s.Require().NoError(s.blocks.Mute(ctx, reader.ID, bob.ID))
+
+	result, err := s.postService.Feed(ctx, reader.ID, PageInput{})
+	s.Require().NoError(err)
+	s.Len(result.Posts, 3)
+	for _, post := range result.Posts {
+		s.Equal(alice.ID, post.UserID)
+	}
+
+	_, err = s.postService.Feed(ctx, reader.ID, PageInput{Cursor: "garbage"})
+	s.EqualError(err, errInvalidCursor)
+}
+
+func (s *PostServiceSuite) TestListByUser() {
+	ctx := context.Background()
+
+	users := make([]*entity.User, 3)
+	for i := range users {
+		uniqueName := "list_posts_" + uuid.New().String()
+		users[i] = &entity.User{
+			Username:     uniqueName,
+			Email:        uniqueName + "@example.com",
+			PasswordHash: "hash",
+		}
+		s.Require().NoError(s.userRepo.Create(ctx, users[i]))
+	}
+	author, viewer, moderator := users[0], users[1], users[2]
+
+	var posts []*entity.Post
+	for i := 0; i < 5; i++ {
+		id, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Hello"})
+		s.Require().NoError(err)
+		post, err := s.postService.GetByID(ctx, author.ID, id)
+		s.Require().NoError(err)
+		posts = append([]*entity.Post{post}, posts...)
+	}
+
+	var got []uuid.UUID
+	page := PageInput{Limit: 2}
+	for pages := 0; ; pages++ {
+		s.Require().Less(pages, 3)
+
+		result, err := s.postService.ListByUser(ctx, viewer.ID, author.ID, page, PostFilter{})
+		s.Require().NoError(err)
+
+		for _, post := range result.Posts {
+			got = append(got, post.ID)
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+	s.Require().Len(got, 5)
+	for i, post := range posts {
+		s.Equal(post.ID, got[i])
+	}
+
+	// posts[3] up to, but not including, posts[1].
+	result, err := s.postService.ListByUser(ctx, viewer.ID, author.ID, PageInput{},
+		PostFilter{Since: &posts[3].CreatedAt, Until: &posts[1].CreatedAt})
+	s.Require().NoError(err)
+	s.Require().Len(result.Posts, 2)
+	s.Equal(posts[2].ID, result.Posts[0].ID)
+	s.Equal(posts[3].ID, result.Posts[1].ID)
+
+	_, err = s.postService.ListByUser(ctx, viewer.ID, author.ID, PageInput{},
+		PostFilter{Since: &posts[1].CreatedAt, Until: &posts[3].CreatedAt})
+	s.EqualError(err, errInvalidTimeRange)
+
+	_, err = s.postService.ListByUser(ctx, viewer.ID, uuid.New(), PageInput{}, PostFilter{})
+	s.EqualError(err, errUserNotFound)
+
+	author.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, author))
+
+	_, err = s.postService.ListByUser(ctx, viewer.ID, author.ID, PageInput{}, PostFilter{})
+	s.EqualError(err, errUserNotFound)
+
+	result, err = s.postService.ListByUser(ctx, author.ID, author.ID, PageInput{}, PostFilter{})
+	s.Require().NoError(err)
+	s.Len(result.Posts, 5)
+
+	s.Require().NoError(s.roleRepo.Assign(ctx, moderator.ID, RoleModerator))
+	_, err = s.postService.ListByUser(ctx, moderator.ID, author.ID, PageInput{}, PostFilter{})
+	s.NoError(err)
+
+	s.Require().NoError(s.blocks.Block(ctx, author.ID, viewer.ID))
+	_, err = s.postService.ListByUser(ctx, viewer.ID, author.ID, PageInput{}, PostFilter{})
+	s.EqualError(err, errUserNotFound)
+}
+
+func (s *PostServiceSuite) TestListByUser_Replies() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "replies_", 3)
+	author, replier, stranger := users[0], users[1], users[2]
+
+	original, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+	top, err := s.postService.Create(ctx, replier.ID, CreatePostInput{Content: "Mine"})
+	s.Require().NoError(err)
+	reply, err := s.postService.Create(ctx, replier.ID, CreatePostInput{Content: "Hi", ReplyToID: &original})
+	s.Require().NoError(err)
+
+	post, err := s.postService.GetByID(ctx, stranger.ID, reply)
+	s.Require().NoError(err)
+	s.Require().NotNil(post.ReplyToID)
+	s.Equal(original, *post.ReplyToID)
+
+	page, err := s.postService.ListByUser(ctx, stranger.ID, replier.ID, PageInput{}, PostFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(page.Posts, 1)
+	s.Equal(top, page.Posts[0].ID)
+
+	page, err = s.postService.ListByUser(ctx, stranger.ID, replier.ID, PageInput{}, PostFilter{IncludeReplies: true})
+	s.Require().NoError(err)
+	s.Require().Len(page.Posts, 2)
+	s.Equal(reply, page.Posts[0].ID)
+
+	// Replies stay replies after the post they answered is gone.
+	s.Require().NoError(s.postService.Delete(ctx, author.ID, original))
+	page, err = s.postService.ListByUser(ctx, stranger.ID, replier.ID, PageInput{}, PostFilter{})
+	s.Require().NoError(err)
+	s.Len(page.Posts, 1)
+
+	_, err = s.postService.Create(ctx, replier.ID, CreatePostInput{Content: "Hi", ReplyToID: &original})
+	s.EqualError(err, errPostNotFound)
+
+	author.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, author))
+	hidden, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Followers only"})
+	s.Require().NoError(err)
+	_, err = s.postService.Create(ctx, stranger.ID, CreatePostInput{Content: "Hi", ReplyToID: &hidden})
+	s.EqualError(err, errPostNotFound)
+}
+
+func (s *PostServiceSuite) TestLikes() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "likes_", 3)
+	author, alice, bob := users[0], users[1], users[2]
+
+	id, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	result, err := s.postService.Like(ctx, alice.ID, id)
+	s.Require().NoError(err)
+	s.Equal(1, result.LikeCount)
+	s.True(result.LikedByMe)
+
+	result, err = s.postService.Like(ctx, alice.ID, id)
+	s.Require().NoError(err)
+	s.Equal(1, result.LikeCount, "liking twice counts once")
+
+	result, err = s.postService.Like(ctx, bob.ID, id)
+	s.Require().NoError(err)
+	s.Equal(2, result.LikeCount)
+
+	post, err := s.postService.GetByID(ctx, alice.ID, id)
+	s.Require().NoError(err)
+	s.Equal(2, post.LikeCount)
+	s.True(post.LikedByMe)
+
+	post, err = s.postService.GetByID(ctx, author.ID, id)
+	s.Require().NoError(err)
+	s.False(post.LikedByMe)
+
+	page, err := s.postService.ListByUser(ctx, bob.ID, author.ID, PageInput{}, PostFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(page.Posts, 1)
+	s.True(page.Posts[0].LikedByMe)
+
+	likes, err := s.postService.ListLikes(ctx, author.ID, id, PageInput{Limit: 1})
+	s.Require().NoError(err)
+	s.Require().Len(likes.Users, 1)
+	s.Equal(bob.ID, likes.Users[0].ID)
+	s.NotEmpty(likes.NextCursor)
+
+	likes, err = s.postService.ListLikes(ctx, author.ID, id, PageInput{Cursor: likes.NextCursor})
+	s.Require().NoError(err)
+	s.Require().Len(likes.Users, 1)
+	s.Equal(alice.ID, likes.Users[0].ID)
+	s.Empty(likes.NextCursor)
+
+	s.Require().NoError(s.blocks.Block(ctx, author.ID, bob.ID))
+	likes, err = s.postService.ListLikes(ctx, author.ID, id, PageInput{})
+	s.Require().NoError(err)
+	s.Require().Len(likes.Users, 1)
+	s.Equal(alice.ID, likes.Users[0].ID, "likers blocked by the viewer are left out")
+	s.Require().NoError(s.blocks.Unblock(ctx, author.ID, bob.ID))
+
+	result, err = s.postService.Unlike(ctx, alice.ID, id)
+	s.Require().NoError(err)
+	s.Equal(1, result.LikeCount)
+	s.False(result.LikedByMe)
+
+	result, err = s.postService.Unlike(ctx, alice.ID, id)
+	s.Require().NoError(err)
+	s.Equal(1, result.LikeCount, "unliking twice uncounts once")
+
+	author.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, author))
+
+	_, err = s.postService.Like(ctx, alice.ID, id)
+	s.EqualError(err, errPostNotFound)
+	_, err = s.postService.ListLikes(ctx, alice.ID, id, PageInput{})
+	s.EqualError(err, errPostNotFound)
+	_, err = s.postService.Unlike(ctx, bob.ID, id)
+	s.EqualError(err, errPostNotFound, "hidden posts answer like missing ones")
+	post, err = s.postService.GetByID(ctx, author.ID, id)
+	s.Require().NoError(err)
+	s.Equal(0, post.LikeCount, "the like is taken back all the same")
+
+	_, err = s.postService.Like(ctx, alice.ID, uuid.New())
+	s.EqualError(err, errPostNotFound)
+	_, err = s.postService.Unlike(ctx, alice.ID, uuid.New())
+	s.EqualError(err, errPostNotFound)
+}
+
+// Likes and unlikes racing each other must leave the count equal to the likes that remain.
+func (s *PostServiceSuite) TestConcurrentLikes() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "concurrent_likes_", 10)
+
+	id, err := s.postService.Create(ctx, users[0].ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	var wg sync.WaitGroup
+	for round := 0; round < 3; round++ {
+		for i, user := range users {
+			wg.Add(1)
+			go func(userID uuid.UUID, unlike bool) {
+				defer wg.Done()
+				_, err := s.postService.Like(ctx, userID, id)
+				s.NoError(err)
+				if unlike {
+					_, err = s.postService.Unlike(ctx, userID, id)
+					s.NoError(err)
+				}
+			}(user.ID, i%2 == 1)
+		}
+	}
+	wg.Wait()
+
+	post, err := s.postService.GetByID(ctx, users[0].ID, id)
+	s.Require().NoError(err)
+	s.Equal(len(users)/2, post.LikeCount)
+
+	likes, err := s.postService.ListLikes(ctx, users[0].ID, id, PageInput{})
+	s.Require().NoError(err)
+	s.Len(likes.Users, post.LikeCount)
+}
+
+func (s *PostServiceSuite) TestReactions() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "reactions_", 3)
+	author, alice, bob := users[0], users[1], users[2]
+
+	id, err := s.postService.Create(ctx, author.ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	post, err := s.postService.GetByID(ctx, alice.ID, id)
+	s.Require().NoError(err)
+	s.NotNil(post.Reactions)
+	s.Empty(post.Reactions)
+	s.NotNil(post.MyReactions)
+	s.Empty(post.MyReactions)
+
+	_, err = s.postService.React(ctx, alice.ID, id, "🦄")
+	s.EqualError(err, errReactionNotAllowed)
+
+	result, err := s.postService.React(ctx, alice.ID, id, "👍")
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": 1}, result.Reactions)
+	s.Equal([]string{"👍"}, result.MyReactions)
+
+	result, err = s.postService.React(ctx, alice.ID, id, "👍")
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": 1}, result.Reactions, "reacting twice counts once")
+
+	_, err = s.postService.React(ctx, alice.ID, id, "😂")
+	s.Require().NoError(err)
+	result, err = s.postService.React(ctx, bob.ID, id, "👍")
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": 2, "😂": 1}, result.Reactions)
+	s.Equal([]string{"👍"}, result.MyReactions)
+
+	post, err = s.postService.GetByID(ctx, alice.ID, id)
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": 2, "😂": 1}, post.Reactions)
+	s.Equal([]string{"👍", "😂"}, post.MyReactions)
+
+	page, err := s.postService.ListByUser(ctx, author.ID, author.ID, PageInput{}, PostFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(page.Posts, 1)
+	s.Equal(map[string]int{"👍": 2, "😂": 1}, page.Posts[0].Reactions)
+	s.Empty(page.Posts[0].MyReactions)
+
+	result, err = s.postService.Unreact(ctx, alice.ID, id, "😂")
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": 2}, result.Reactions)
+	s.Equal([]string{"👍"}, result.MyReactions)
+
+	result, err = s.postService.Unreact(ctx, alice.ID, id, "😂")
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": 2}, result.Reactions, "taking back twice uncounts once")
+
+	author.IsPrivate = true
+	s.Require().NoError(s.userRepo.Update(ctx, author))
+
+	_, err = s.postService.React(ctx, alice.ID, id, "❤️")
+	s.EqualError(err, errPostNotFound)
+	_, err = s.postService.Unreact(ctx, alice.ID, id, "👍")
+	s.EqualError(err, errPostNotFound, "hidden posts answer like missing ones")
+	post, err = s.postService.GetByID(ctx, author.ID, id)
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": 1}, post.Reactions, "the reaction is taken back all the same")
+
+	_, err = s.postService.React(ctx, alice.ID, uuid.New(), "👍")
+	s.EqualError(err, errPostNotFound)
+	_, err = s.postService.Unreact(ctx, alice.ID, uuid.New(), "👍")
+	s.EqualError(err, errPostNotFound)
+}
+
+func (s *PostServiceSuite) TestReactions_Allowlist() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "reaction_allowlist_", 1)
+
+	postService := NewPostService(newTestRepository(s.pool), PostConfig{Reactions: []string{"🦄"}})
+	id, err := postService.Create(ctx, users[0].ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	_, err = postService.React(ctx, users[0].ID, id, "👍")
+	s.EqualError(err, errReactionNotAllowed)
+
+	result, err := postService.React(ctx, users[0].ID, id, "🦄")
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"🦄": 1}, result.Reactions)
+}
+
+// Reactions racing each other must leave every count equal to the reactions that remain.
+func (s *PostServiceSuite) TestConcurrentReactions() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "concurrent_reactions_", 10)
+
+	id, err := s.postService.Create(ctx, users[0].ID, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+
+	var wg sync.WaitGroup
+	for round := 0; round < 3; round++ {
+		for i, user := range users {
+			for _, emoji := range []string{"👍", "❤️"} {
+				wg.Add(1)
+				go func(userID uuid.UUID, emoji string, unreact bool) {
+					defer wg.Done()
+					_, err := s.postService.React(ctx, userID, id, emoji)
+					s.NoError(err)
+					if unreact {
+						_, err = s.postService.Unreact(ctx, userID, id, emoji)
+						s.NoError(err)
+					}
+				}(user.ID, emoji, i%2 == 1)
+			}
+		}
+	}
+	wg.Wait()
+
+	post, err := s.postService.GetByID(ctx, users[0].ID, id)
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"👍": len(users) / 2, "❤️": len(users) / 2}, post.Reactions)
+}
+
 func TestPostService(t *testing.T) {
 	suite.Run(t, new(PostServiceSuite))
 }
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// AssignRole grants a role to a user. The user's permissions change in their access tokens
+// once the tokens are refreshed, and in the policy checks of the services at once.
+func (s *authService) AssignRole(ctx context.Context, actorID, userID uuid.UUID, role string) error {
+	if err := s.requirePermission(ctx, actorID, PermissionRolesManage); err != nil {
+		return err
+	}
+
+	return s.access.roleRepo.Assign(ctx, userID, role)
+}
+
+func (s *authService) RemoveRole(ctx context.Context, actorID, userID uuid.UUID, role string) error {
+	if err := s.requirePermission(ctx, actorID, PermissionRolesManage); err != nil {
+		return err
+	}
+
+	return s.access.roleRepo.Remove(ctx, userID, role)
+}
+
+func (s *authService) requirePermission(ctx context.Context, userID uuid.UUID, permission string) error {
+	allowed, err := s.access.can(ctx, userID, permission)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return errors.New("forbidden")
+	}
+
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/defskela/SocialNetwork/internal/entity"
 	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+	"github.com/defskela/SocialNetwork/pkg/mailer"
 
 	"github.com/google/uuid"
 )
@@ -19,22 +21,138 @@ type SignUpInput struct {
 type SignInInput struct {
 	Email    string `json:"email" validate:"required,email" example:"john@example.com"`
 	Password string `json:"password" validate:"required" example:"secret123"`
+	Device   string `json:"-"`
+	IP       string `json:"-"`
 }
 
+type OIDCCallbackInput struct {
+	Provider string `json:"-"`
+	Code     string `json:"code" validate:"required"`
+	State    string `json:"state" validate:"required"`
+	Device   string `json:"-"`
+	IP       string `json:"-"`
+}
+
+type MagicLinkInput struct {
+	Email string `json:"email" validate:"required,email" example:"john@example.com"`
+}
+
+type ConsumeMagicLinkInput struct {
+	Token  string `json:"token" validate:"required" example:"eyJhbGciOi..."`
+	Device string `json:"-"`
+	IP     string `json:"-"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required" example:"3f2b9c..."`
+	Device       string `json:"-"`
+	IP           string `json:"-"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token" validate:"required" example:"eyJhbGciOiJSUzI1NiIs..."`
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email" validate:"required,email" example:"john@example.com"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email" example:"john@example.com"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" validate:"required" example:"9b1c4e..."`
+	Password string `json:"password" validate:"required,min=8" example:"newSecret123"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required" example:"secret123"`
+	NewPassword     string `json:"new_password" validate:"required" example:"newSecret123"`
+}
+
+type SignInMFAInput struct {
+	MFAToken string `json:"mfa_token" validate:"required" example:"eyJhbGciOiJSUzI1NiIs..."`
+	Code     string `json:"code" validate:"required" example:"123456"`
+	Device   string `json:"-"`
+	IP       string `json:"-"`
+}
+
+type TOTPCodeInput struct {
+	Code string `json:"code" validate:"required" example:"123456"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"otpauth_uri" example:"otpauth://totp/SocialNetwork:john@example.com?secret=JBSWY3DPEHPK3PXP"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes" example:"3f2b9-c41de"`
+}
+
+// Tokens is the result of a sign in. When the account has two-factor authentication on,
+// only MFARequired and MFAToken are set and the token has to be exchanged at /auth/login/mfa.
 type Tokens struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type CreatePersonalAccessTokenInput struct {
+	Name      string     `json:"name" validate:"required,max=100" example:"deploy bot"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,scope" example:"posts:write"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2030-01-01T00:00:00Z"`
+}
+
+// CreatedPersonalAccessToken is returned once, when the token is created. The token itself is not stored.
+type CreatedPersonalAccessToken struct {
+	Token string `json:"token" example:"snp_3f2b9c..."`
+	*entity.PersonalAccessToken
 }
 
 type AuthService interface {
 	SignUp(ctx context.Context, input SignUpInput) (uuid.UUID, error)
 	SignIn(ctx context.Context, input SignInInput) (Tokens, error)
-	ParseToken(accessToken string) (uuid.UUID, error)
+	SignInMFA(ctx context.Context, input SignInMFAInput) (Tokens, error)
+	OIDCAuthURL(ctx context.Context, provider string) (string, error)
+	SignInOIDC(ctx context.Context, input OIDCCallbackInput) (Tokens, error)
+	SendMagicLink(ctx context.Context, input MagicLinkInput) error
+	ConsumeMagicLink(ctx context.Context, input ConsumeMagicLinkInput) (Tokens, error)
+	Refresh(ctx context.Context, input RefreshInput) (Tokens, error)
+	Logout(ctx context.Context, userID, sessionID uuid.UUID) error
+	LogoutAll(ctx context.Context, userID uuid.UUID) error
+	ListSessions(ctx context.Context, userID, currentSessionID uuid.UUID) ([]entity.Session, error)
+	VerifyEmail(ctx context.Context, input VerifyEmailInput) error
+	ResendVerification(ctx context.Context, input ResendVerificationInput) error
+	ForgotPassword(ctx context.Context, input ForgotPasswordInput) error
+	ResetPassword(ctx context.Context, input ResetPasswordInput) error
+	ChangePassword(ctx context.Context, userID, currentSessionID uuid.UUID, input ChangePasswordInput) error
+	EnrollTOTP(ctx context.Context, userID uuid.UUID) (TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, input TOTPCodeInput) (RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, userID uuid.UUID, input TOTPCodeInput) error
+	UnlockAccount(ctx context.Context, actorID, userID uuid.UUID) error
+	AssignRole(ctx context.Context, actorID, userID uuid.UUID, role string) error
+	RemoveRole(ctx context.Context, actorID, userID uuid.UUID, role string) error
+	CreatePersonalAccessToken(
+		ctx context.Context,
+		userID uuid.UUID,
+		input CreatePersonalAccessTokenInput,
+	) (CreatedPersonalAccessToken, error)
+	ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]entity.PersonalAccessToken, error)
+	RevokePersonalAccessToken(ctx context.Context, userID, tokenID uuid.UUID) error
+	ParseToken(ctx context.Context, accessToken string) (*AuthClaim, error)
+	JWKS() keyring.JWKS
 }
 
 type UserService interface {
 	GetProfile(ctx context.Context, userID uuid.UUID) (*entity.User, error)
 	UpdateProfile(ctx context.Context, userID uuid.UUID, input UpdateUserInput) (*entity.User, error)
+	// GetPublicProfile and GetPublicProfileByUsername return what viewerID may see of a user.
+	// viewerID is uuid.Nil for anonymous callers.
+	GetPublicProfile(ctx context.Context, viewerID, userID uuid.UUID) (*entity.PublicProfile, error)
+	GetPublicProfileByUsername(ctx context.Context, viewerID uuid.UUID, username string) (*entity.PublicProfile, error)
 }
 
 type UpdateUserInput struct {
@@ -42,10 +160,16 @@ type UpdateUserInput struct {
 	Email    *string `json:"email" validate:"omitempty,email" example:"john@example.com"`
 	Bio      *string `json:"bio" validate:"omitempty,max=500" example:"Software Engineer"`
 	Birthday *string `json:"birthday" validate:"omitempty,datetime=2006-01-02" example:"2006-01-02"`
+	// BirthdayVisibility is public to show the birthday on the public profile, or private to hide it.
+	BirthdayVisibility *string `json:"birthday_visibility" validate:"omitempty,oneof=public private" example:"public"`
+	// IsPrivate makes new followers need approval and hides posts from everyone else.
+	IsPrivate *bool `json:"is_private" example:"false"`
 }
 
 type CreatePostInput struct {
 	Content string `json:"content" validate:"required,min=1,max=2000" example:"Hello, world!"`
+	// ReplyToID makes the post a reply to another post the author can see.
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
 }
 
 type UpdatePostInput struct {
@@ -54,29 +178,115 @@ type UpdatePostInput struct {
 
 type PostService interface {
 	Create(ctx context.Context, userID uuid.UUID, input CreatePostInput) (uuid.UUID, error)
-	GetByID(ctx context.Context, id uuid.UUID) (*entity.Post, error)
+	GetByID(ctx context.Context, viewerID, id uuid.UUID) (*entity.Post, error)
 	Update(ctx context.Context, userID uuid.UUID, postID uuid.UUID, input UpdatePostInput) (*entity.Post, error)
 	Delete(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error
+	Feed(ctx context.Context, userID uuid.UUID, page PageInput) (*PostPage, error)
+	ListByUser(ctx context.Context, viewerID, userID uuid.UUID, page PageInput, filter PostFilter) (*PostPage, error)
+	Like(ctx context.Context, userID, postID uuid.UUID) (*LikeResult, error)
+	Unlike(ctx context.Context, userID, postID uuid.UUID) (*LikeResult, error)
+	ListLikes(ctx context.Context, viewerID, postID uuid.UUID, page PageInput) (*LikePage, error)
+	React(ctx context.Context, userID, postID uuid.UUID, emoji string) (*ReactionResult, error)
+	Unreact(ctx context.Context, userID, postID uuid.UUID, emoji string) (*ReactionResult, error)
+}
+
+// LikeResult is where a post stands after a like or unlike.
+type LikeResult struct {
+	LikeCount int  `json:"like_count" example:"42"`
+	LikedByMe bool `json:"liked_by_me" example:"true"`
+}
+
+// ReactionResult is where the reactions on a post stand after a reaction is added or taken back.
+type ReactionResult struct {
+	Reactions   map[string]int `json:"reactions"`
+	MyReactions []string       `json:"my_reactions" example:"👍"`
+}
+
+// LikePage is a page of the users who liked a post. NextCursor is empty on the last page.
+type LikePage struct {
+	Users      []entity.Liker `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// PostFilter narrows a list of posts to those created at or after Since and before Until.
+// Replies are left out unless IncludeReplies is set.
+type PostFilter struct {
+	Since          *time.Time
+	Until          *time.Time
+	IncludeReplies bool
+}
+
+// PostPage is a page of posts. NextCursor is empty on the last page.
+type PostPage struct {
+	Posts      []entity.Post `json:"posts"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type FollowService interface {
+	Follow(ctx context.Context, followerID, followeeID uuid.UUID) (string, error)
+	Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error
+	ListFollowers(ctx context.Context, viewerID, userID uuid.UUID, page PageInput) (*FollowPage, error)
+	ListFollowing(ctx context.Context, viewerID, userID uuid.UUID, page PageInput) (*FollowPage, error)
+	ListFollowRequests(ctx context.Context, userID uuid.UUID, page PageInput) (*FollowPage, error)
+	AcceptFollowRequest(ctx context.Context, userID, requesterID uuid.UUID) error
+	RejectFollowRequest(ctx context.Context, userID, requesterID uuid.UUID) error
+}
+
+// FollowResult tells whether a follow took effect or waits for the account owner to accept it.
+type FollowResult struct {
+	Status string `json:"status" example:"following"`
+}
+
+// FollowPage is a page of followers or followings. NextCursor is empty on the last page.
+type FollowPage struct {
+	Users      []entity.FollowUser `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type BlockService interface {
+	Block(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	Unblock(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	Mute(ctx context.Context, muterID, mutedID uuid.UUID) error
+	Unmute(ctx context.Context, muterID, mutedID uuid.UUID) error
+	ListBlocked(ctx context.Context, userID uuid.UUID, page PageInput) (*RestrictedPage, error)
+	ListMuted(ctx context.Context, userID uuid.UUID, page PageInput) (*RestrictedPage, error)
+}
+
+// RestrictedPage is a page of blocked or muted users. NextCursor is empty on the last page.
+type RestrictedPage struct {
+	Users      []entity.RestrictedUser `json:"users"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
 }
 
 type Service struct {
-	Auth AuthService
-	User UserService
-	Post PostService
+	Auth   AuthService
+	User   UserService
+	Post   PostService
+	Follow FollowService
+	Block  BlockService
+}
+
+type Config struct {
+	Auth AuthConfig
+	Post PostConfig
 }
 
-func NewService(repos *repository.Repository, privKeyPath, pubKeyPath string) (*Service, error) {
-	authService, err := NewAuthService(repos.User, 12*time.Hour, privKeyPath, pubKeyPath)
+func NewService(repos *repository.Repository, mail mailer.Mailer, cfg Config) (*Service, error) {
+	authService, err := NewAuthService(repos, mail, cfg.Auth)
 	if err != nil {
 		return nil, err
 	}
 
-	userService := NewUserService(repos.User)
-	postService := NewPostService(repos.Post)
+	userService := NewUserService(repos)
+	postService := NewPostService(repos, cfg.Post)
+	followService := NewFollowService(repos)
+	blockService := NewBlockService(repos)
 
 	return &Service{
-		Auth: authService,
-		User: userService,
-		Post: postService,
+		Auth:   authService,
+		User:   userService,
+		Post:   postService,
+		Follow: followService,
+		Block:  blockService,
 	}, nil
 }
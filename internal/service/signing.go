@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+)
+
+// signingAlgorithms are the only algorithms tokens are accepted with.
+var signingAlgorithms = []string{keyring.RS256, keyring.ES256, keyring.EdDSA}
+
+// JWKS returns the public keys that verify tokens issued by this service.
+func (s *authService) JWKS() keyring.JWKS {
+	return s.keys.JWKS()
+}
+
+// sign signs claims with the active key of the ring and names the key in the kid header.
+func (s *authService) sign(claims jwt.Claims) (string, error) {
+	key := s.keys.Active()
+
+	method := jwt.GetSigningMethod(key.Algorithm)
+	if method == nil {
+		return "", fmt.Errorf("unsupported signing algorithm: %s", key.Algorithm)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.Private)
+}
+
+// parse verifies tokenString into claims. The parser only accepts the algorithms above,
+// and verificationKey makes sure the key found for the token is of the type its alg names.
+// Every token must come from our issuer; callers add further checks through opts.
+func (s *authService) parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append([]jwt.ParserOption{
+		jwt.WithValidMethods(signingAlgorithms),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithLeeway(s.leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	}, opts...)
+
+	return jwt.ParseWithClaims(tokenString, claims, s.verificationKey, opts...)
+}
+
+// verificationKey picks the key that signed token. Tokens issued before keys had ids
+// carry no kid and are checked against every key of the ring made for their algorithm.
+func (s *authService) verificationKey(token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		set := jwt.VerificationKeySet{}
+		for _, key := range s.keys.Keys() {
+			if key.Algorithm == alg {
+				set.Keys = append(set.Keys, key.Public)
+			}
+		}
+		if len(set.Keys) == 0 {
+			return nil, fmt.Errorf("no key for signing method: %s", alg)
+		}
+		return set, nil
+	}
+
+	key, ok := s.keys.Get(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+
+	if key.Algorithm != alg {
+		return nil, fmt.Errorf("signing method %s does not match key %s", alg, kid)
+	}
+
+	return key.Public, nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/pkg/cache"
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+)
+
+func TestSigning_Algorithms(t *testing.T) {
+	dir := t.TempDir()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ed.pem"), data, 0o600))
+
+	rsaRing, err := keyring.FromFiles("../../certs/local/private.pem", "../../certs/local/public.pem", keyring.RS256)
+	require.NoError(t, err)
+	rsaKey := rsaRing.Active()
+
+	rsaPub, err := x509.MarshalPKIXPublicKey(rsaKey.Public)
+	require.NoError(t, err)
+	data = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPub})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rsa.pub.pem"), data, 0o600))
+
+	ring, err := keyring.Load(dir, keyring.EdDSA)
+	require.NoError(t, err)
+
+	s := &authService{keys: ring}
+	userID := uuid.New()
+
+	t.Run("Signs with the configured algorithm", func(t *testing.T) {
+		token, err := s.signActionToken(purposeMFA, userID, "", time.Minute)
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &actionClaim{})
+		require.NoError(t, err)
+		assert.Equal(t, "EdDSA", parsed.Header["alg"])
+		assert.Equal(t, "ed", parsed.Header["kid"])
+
+		_, id, err := s.parseActionToken(token, purposeMFA)
+		require.NoError(t, err)
+		assert.Equal(t, userID, id)
+	})
+
+	t.Run("Accepts tokens from a retired key of another algorithm", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, &actionClaim{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   userID.String(),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Purpose: purposeMFA,
+		})
+		token.Header["kid"] = "rsa"
+		signed, err := token.SignedString(rsaKey.Private)
+		require.NoError(t, err)
+
+		_, _, err = s.parseActionToken(signed, purposeMFA)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Rejects an algorithm that does not match the key", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, &actionClaim{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   userID.String(),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Purpose: purposeMFA,
+		})
+		token.Header["kid"] = "ed"
+		signed, err := token.SignedString(rsaKey.Private)
+		require.NoError(t, err)
+
+		_, _, err = s.parseActionToken(signed, purposeMFA)
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects HMAC", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &actionClaim{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   userID.String(),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Purpose: purposeMFA,
+		})
+		signed, err := token.SignedString(rsaPub)
+		require.NoError(t, err)
+
+		_, _, err = s.parseActionToken(signed, purposeMFA)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseToken_StandardClaims(t *testing.T) {
+	ring, err := keyring.FromFiles("../../certs/local/private.pem", "../../certs/local/public.pem", keyring.RS256)
+	require.NoError(t, err)
+
+	userID, sessionID := uuid.New(), uuid.New()
+	roles := &stubRoleRepository{roles: map[uuid.UUID][]entity.Role{
+		userID: {{Name: RoleModerator, Permissions: []string{PermissionPostsModerate}}},
+	}}
+
+	newService := func(issuer, audience string) *authService {
+		return &authService{
+			keys:        ring,
+			issuer:      issuer,
+			audience:    audience,
+			leeway:      30 * time.Second,
+			tokenTTL:    time.Hour,
+			revocations: cache.NewTTL[uuid.UUID, bool](10),
+			access:      newAccessPolicy(roles),
+		}
+	}
+
+	s := newService("https://auth.example.com", "api")
+	// Pretend the session was already checked, so ParseToken does not need a database.
+	s.revocations.Set(sessionID, false, time.Hour)
+
+	claimsAt := func(notBefore, expiresAt time.Time) *AuthClaim {
+		return &AuthClaim{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Issuer:    s.issuer,
+				Subject:   userID.String(),
+				Audience:  jwt.ClaimStrings{s.audience},
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				NotBefore: jwt.NewNumericDate(notBefore),
+				IssuedAt:  jwt.NewNumericDate(notBefore),
+			},
+			UserID:    userID,
+			SessionID: sessionID,
+		}
+	}
+
+	t.Run("Issued tokens carry the standard claims", func(t *testing.T) {
+		tokens, _, err := s.newTokens(context.Background(), userID, sessionID, "")
+		require.NoError(t, err)
+
+		claims, err := s.ParseToken(context.Background(), tokens.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, "https://auth.example.com", claims.Issuer)
+		assert.Equal(t, userID.String(), claims.Subject)
+		assert.Equal(t, jwt.ClaimStrings{"api"}, claims.Audience)
+		assert.NotEmpty(t, claims.ID)
+		assert.NotNil(t, claims.NotBefore)
+		assert.Equal(t, userID, claims.UserID)
+		assert.Equal(t, []string{RoleModerator}, claims.Roles)
+		assert.True(t, claims.HasPermission(PermissionPostsModerate))
+	})
+
+	t.Run("Wrong issuer or audience", func(t *testing.T) {
+		tokens, _, err := s.newTokens(context.Background(), userID, sessionID, "")
+		require.NoError(t, err)
+
+		_, err = newService("https://other.example.com", "api").ParseToken(context.Background(), tokens.AccessToken)
+		assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+		_, err = newService("https://auth.example.com", "other").ParseToken(context.Background(), tokens.AccessToken)
+		assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+	})
+
+	t.Run("Leeway", func(t *testing.T) {
+		now := time.Now()
+
+		token, err := s.sign(claimsAt(now.Add(10*time.Second), now.Add(time.Hour)))
+		require.NoError(t, err)
+		_, err = s.ParseToken(context.Background(), token)
+		assert.NoError(t, err, "a token from a clock slightly ahead is accepted")
+
+		token, err = s.sign(claimsAt(now.Add(time.Minute), now.Add(time.Hour)))
+		require.NoError(t, err)
+		_, err = s.ParseToken(context.Background(), token)
+		assert.ErrorIs(t, err, jwt.ErrTokenNotValidYet)
+
+		token, err = s.sign(claimsAt(now.Add(-time.Hour), now.Add(-10*time.Second)))
+		require.NoError(t, err)
+		_, err = s.ParseToken(context.Background(), token)
+		assert.NoError(t, err, "a token that just expired is accepted within the leeway")
+
+		token, err = s.sign(claimsAt(now.Add(-time.Hour), now.Add(-time.Minute)))
+		require.NoError(t, err)
+		_, err = s.ParseToken(context.Background(), token)
+		assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	})
+
+	t.Run("Subject must match user", func(t *testing.T) {
+		claims := claimsAt(time.Now(), time.Now().Add(time.Hour))
+		claims.Subject = uuid.NewString()
+
+		token, err := s.sign(claims)
+		require.NoError(t, err)
+		_, err = s.ParseToken(context.Background(), token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Action tokens are not access tokens", func(t *testing.T) {
+		token, err := s.signActionToken(purposeMFA, userID, "", time.Minute)
+		require.NoError(t, err)
+
+		_, err = s.ParseToken(context.Background(), token)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/defskela/SocialNetwork/internal/entity"
+	"github.com/defskela/SocialNetwork/internal/repository"
+)
+
+type TimelineConfig struct {
+	// CelebrityFollowers is the follower count from which an author's posts are not written to every
+	// follower's timeline but read from the author when a feed is loaded. It is applied when a post is
+	// fanned out and the post keeps being read that way after the author falls below it.
+	CelebrityFollowers int
+	// BatchSize posts are fanned out per round trip to the database.
+	BatchSize int
+	// PollInterval is how often the worker looks for posts it was not woken up for,
+	// such as those created by another instance.
+	PollInterval time.Duration
+}
+
+// TimelineWorker writes new posts to the timelines of their authors' followers in the background,
+// so that loading a feed reads one user's timeline instead of joining the posts of everyone they follow.
+type TimelineWorker struct {
+	repo repository.TimelineRepository
+	cfg  TimelineConfig
+	wake chan struct{}
+}
+
+func NewTimelineWorker(repos *repository.Repository, cfg TimelineConfig) *TimelineWorker {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+
+	return &TimelineWorker{
+		repo: repos.Timeline,
+		cfg:  cfg,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Run fans out posts until ctx is done.
+func (w *TimelineWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.drain(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("failed to fan out posts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Wake tells the worker there is a new post without waiting for it.
+func (w *TimelineWorker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drain fans out batches until no posts are left waiting.
+func (w *TimelineWorker) drain(ctx context.Context) error {
+	for {
+		n, err := w.repo.FanOut(ctx, w.cfg.BatchSize, w.cfg.CelebrityFollowers)
+		if err != nil {
+			return err
+		}
+
+		if n < w.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (w *TimelineWorker) list(
+	ctx context.Context,
+	userID uuid.UUID,
+	after *entity.Cursor,
+	limit int,
+) ([]entity.Post, error) {
+	return w.repo.List(ctx, userID, after, limit)
+}
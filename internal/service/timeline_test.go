@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/defskela/SocialNetwork/internal/config"
+	"github.com/defskela/SocialNetwork/internal/repository"
+	"github.com/defskela/SocialNetwork/pkg/client/postgresql"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+)
+
+type TimelineSuite struct {
+	suite.Suite
+	pool        *pgxpool.Pool
+	timeline    *TimelineWorker
+	postService PostService
+	follows     FollowService
+	blocks      BlockService
+	userRepo    repository.UserRepository
+}
+
+func (s *TimelineSuite) SetupSuite() {
+	cfg := config.MustLoadPath("../../configs/local.yaml")
+	cfg.Postgres.Host = testDBHost
+
+	var err error
+	s.pool, err = postgresql.NewClient(context.Background(), 3, &cfg.Postgres)
+	s.Require().NoError(err)
+}
+
+func (s *TimelineSuite) TearDownSuite() {
+	if s.pool != nil {
+		s.pool.Close()
+	}
+}
+
+func (s *TimelineSuite) SetupTest() {
+	repos := newTestRepository(s.pool)
+	s.userRepo = repos.User
+	s.timeline = NewTimelineWorker(repos, TimelineConfig{CelebrityFollowers: 2, PollInterval: time.Hour})
+	s.postService = NewPostService(repos, PostConfig{Timeline: s.timeline})
+	s.follows = NewFollowService(repos)
+	s.blocks = NewBlockService(repos)
+}
+
+func (s *TimelineSuite) post(author uuid.UUID) uuid.UUID {
+	id, err := s.postService.Create(context.Background(), author, CreatePostInput{Content: "Hello"})
+	s.Require().NoError(err)
+	return id
+}
+
+func (s *TimelineSuite) feed(userID uuid.UUID) []uuid.UUID {
+	page, err := s.postService.Feed(context.Background(), userID, PageInput{})
+	s.Require().NoError(err)
+
+	ids := make([]uuid.UUID, len(page.Posts))
+	for i, post := range page.Posts {
+		ids[i] = post.ID
+	}
+	return ids
+}
+
+func (s *TimelineSuite) inboxes(postID uuid.UUID) int {
+	var n int
+	err := s.pool.QueryRow(context.Background(),
+		"SELECT count(*) FROM social.timelines WHERE post_id = $1", postID).Scan(&n)
+	s.Require().NoError(err)
+	return n
+}
+
+func (s *TimelineSuite) TestFanOut() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "timeline_tester_", 4)
+	author, celebrity, alice, bob := users[0].ID, users[1].ID, users[2].ID, users[3].ID
+
+	for _, pair := range [][2]uuid.UUID{{alice, author}, {alice, celebrity}, {bob, celebrity}} {
+		_, err := s.follows.Follow(ctx, pair[0], pair[1])
+		s.Require().NoError(err)
+	}
+
+	first := s.post(author)
+	famous := s.post(celebrity)
+
+	// Posts show up before the worker gets to them.
+	s.Equal([]uuid.UUID{famous, first}, s.feed(alice))
+
+	s.Require().NoError(s.timeline.drain(ctx))
+	s.Equal(1, s.inboxes(first))
+	s.Equal(0, s.inboxes(famous), "celebrity posts are read when the feed loads")
+	s.Equal([]uuid.UUID{famous, first}, s.feed(alice))
+	s.Equal([]uuid.UUID{famous}, s.feed(bob))
+
+	// A new follower gets the latest posts straight away.
+	_, err := s.follows.Follow(ctx, bob, author)
+	s.Require().NoError(err)
+	s.Equal(2, s.inboxes(first))
+	s.Equal([]uuid.UUID{famous, first}, s.feed(bob))
+
+	s.Require().NoError(s.blocks.Mute(ctx, bob, celebrity))
+	s.Equal([]uuid.UUID{first}, s.feed(bob))
+
+	s.Require().NoError(s.follows.Unfollow(ctx, bob, author))
+	s.Empty(s.feed(bob))
+
+	s.Require().NoError(s.postService.Delete(ctx, author, first))
+	s.Equal(0, s.inboxes(first))
+	s.Equal([]uuid.UUID{famous}, s.feed(alice))
+
+	// Skipped posts stay in feeds after their author falls below the threshold.
+	s.Require().NoError(s.follows.Unfollow(ctx, bob, celebrity))
+	s.Equal([]uuid.UUID{famous}, s.feed(alice))
+}
+
+func (s *TimelineSuite) TestPagination() {
+	ctx := context.Background()
+	users := createTestUsers(s.T(), s.userRepo, "timeline_tester_", 4)
+	reader, celebrity := users[0].ID, users[1].ID
+
+	for _, author := range users[1:] {
+		_, err := s.follows.Follow(ctx, reader, author.ID)
+		s.Require().NoError(err)
+	}
+	_, err := s.follows.Follow(ctx, users[2].ID, celebrity)
+	s.Require().NoError(err)
+
+	var want []uuid.UUID
+	for i := 0; i < 3; i++ {
+		for _, author := range users[1:] {
+			want = append([]uuid.UUID{s.post(author.ID)}, want...)
+		}
+		if i == 1 {
+			s.Require().NoError(s.timeline.drain(ctx))
+		}
+	}
+
+	var got []uuid.UUID
+	page := PageInput{Limit: 4}
+	for pages := 0; ; pages++ {
+		s.Require().Less(pages, 3)
+
+		result, err := s.postService.Feed(ctx, reader, page)
+		s.Require().NoError(err)
+
+		for _, post := range result.Posts {
+			got = append(got, post.ID)
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+	s.Equal(want, got)
+}
+
+func (s *TimelineSuite) TestRun() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.timeline.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	users := createTestUsers(s.T(), s.userRepo, "timeline_tester_", 2)
+	_, err := s.follows.Follow(context.Background(), users[1].ID, users[0].ID)
+	s.Require().NoError(err)
+
+	id := s.post(users[0].ID)
+	s.Eventually(func() bool { return s.inboxes(id) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTimeline(t *testing.T) {
+	suite.Run(t, new(TimelineSuite))
+}
+
+// BenchmarkFeed compares loading a page of the home feed from the materialized timeline with
+// querying it from the posts of every followed user, for a reader following 500 users with 200 posts each.
+func BenchmarkFeed(b *testing.B) {
+	const (
+		authors = 500
+		posts   = 200
+	)
+
+	cfg := config.MustLoadPath("../../configs/local.yaml")
+	cfg.Postgres.Host = testDBHost
+
+	ctx := context.Background()
+	pool, err := postgresql.NewClient(ctx, 3, &cfg.Postgres)
+	if err != nil {
+		b.Skipf("Could not connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	run := uuid.NewString()
+	var reader uuid.UUID
+	err = pool.QueryRow(ctx, `
+		INSERT INTO social.users (username, email, password_hash)
+		VALUES ('bench_reader_' || $1, 'bench_reader_' || $1 || '@example.com', 'hash')
+		RETURNING id
+	`, run).Scan(&reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	seed := []string{`
+		INSERT INTO social.users (username, email, password_hash)
+		SELECT 'bench_' || $1 || '_' || g, 'bench_' || $1 || '_' || g || '@example.com', 'hash'
+		FROM generate_series(1, $3) g
+	`, `
+		INSERT INTO social.follows (follower_id, followee_id)
+		SELECT $2, id FROM social.users WHERE username LIKE 'bench\_' || $1 || '\_%'
+	`, `
+		INSERT INTO social.posts (user_id, content, created_at)
+		SELECT u.id, 'Hello', CURRENT_TIMESTAMP - g * interval '1 second' - random() * interval '1 hour'
+		FROM social.users u, generate_series(1, $4) g
+		WHERE u.username LIKE 'bench\_' || $1 || '\_%'
+	`}
+	for _, q := range seed {
+		if _, err := pool.Exec(ctx, q, run, reader, authors, posts); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	repos := newTestRepository(pool)
+	timeline := NewTimelineWorker(repos, TimelineConfig{CelebrityFollowers: 10000, BatchSize: 1000})
+	if err := timeline.drain(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bc := range []struct {
+		name string
+		cfg  PostConfig
+	}{
+		{"query", PostConfig{}},
+		{"timeline", PostConfig{Timeline: timeline}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			postService := NewPostService(repos, bc.cfg)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				page, err := postService.Feed(ctx, reader, PageInput{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(page.Posts) != DefaultPageSize {
+					b.Fatalf("got %d posts", len(page.Posts))
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,13 +12,17 @@ import (
 	"github.com/defskela/SocialNetwork/internal/repository"
 )
 
+const errUserNotFound = "user not found"
+
 type userService struct {
-	repo repository.UserRepository
+	repo      repository.UserRepository
+	blockRepo repository.BlockRepository
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
+func NewUserService(repos *repository.Repository) UserService {
 	return &userService{
-		repo: repo,
+		repo:      repos.User,
+		blockRepo: repos.Block,
 	}
 }
 
@@ -51,6 +56,12 @@ func (s *userService) UpdateProfile(
 		}
 		user.Birthday = &t
 	}
+	if input.BirthdayVisibility != nil {
+		user.BirthdayVisibility = *input.BirthdayVisibility
+	}
+	if input.IsPrivate != nil {
+		user.IsPrivate = *input.IsPrivate
+	}
 
 	if err := s.repo.Update(ctx, user); err != nil {
 		return nil, err
@@ -58,3 +69,47 @@ func (s *userService) UpdateProfile(
 
 	return user, nil
 }
+
+func (s *userService) GetPublicProfile(ctx context.Context, viewerID, userID uuid.UUID) (*entity.PublicProfile, error) {
+	profile, err := s.repo.GetPublicProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.publicView(ctx, viewerID, profile)
+}
+
+func (s *userService) GetPublicProfileByUsername(
+	ctx context.Context,
+	viewerID uuid.UUID,
+	username string,
+) (*entity.PublicProfile, error) {
+	profile, err := s.repo.GetPublicProfileByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.publicView(ctx, viewerID, profile)
+}
+
+// publicView hides the birthday unless the user shares it or is looking at their own profile.
+// Users who blocked each other do not see each other's profiles at all.
+func (s *userService) publicView(
+	ctx context.Context,
+	viewerID uuid.UUID,
+	profile *entity.PublicProfile,
+) (*entity.PublicProfile, error) {
+	blocked, err := blockedBetween(ctx, s.blockRepo, viewerID, profile.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if blocked {
+		return nil, errors.New(errUserNotFound)
+	}
+
+	if profile.BirthdayVisibility != entity.BirthdayVisibilityPublic && viewerID != profile.ID {
+		profile.Birthday = nil
+	}
+	return profile, nil
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS social.sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_sessions_user_id ON social.sessions(user_id);
+
+INSERT INTO social.sessions (id, user_id, created_at, revoked_at)
+SELECT family_id,
+       user_id,
+       MIN(created_at),
+       CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM social.refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE social.refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_session
+    FOREIGN KEY (family_id) REFERENCES social.sessions(id) ON DELETE CASCADE;
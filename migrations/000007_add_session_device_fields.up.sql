@@ -0,0 +1,19 @@
+ALTER TABLE social.sessions ADD COLUMN user_agent VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE social.sessions ADD COLUMN ip VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE social.sessions ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE social.sessions ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE social.sessions s
+SET user_agent = t.device,
+    last_used_at = t.created_at,
+    expires_at = t.expires_at
+FROM (
+    SELECT DISTINCT ON (family_id) family_id, device, created_at, expires_at
+    FROM social.refresh_tokens
+    ORDER BY family_id, created_at DESC
+) t
+WHERE t.family_id = s.id;
+
+UPDATE social.sessions SET expires_at = created_at WHERE expires_at IS NULL;
+
+ALTER TABLE social.sessions ALTER COLUMN expires_at SET NOT NULL;
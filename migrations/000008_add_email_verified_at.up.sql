@@ -0,0 +1 @@
+ALTER TABLE social.users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS social.login_throttles (
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    blocked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (scope, key)
+);
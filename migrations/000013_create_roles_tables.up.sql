@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS social.roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS social.role_permissions (
+    role VARCHAR(32) NOT NULL REFERENCES social.roles(name) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS social.user_roles (
+    user_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    role VARCHAR(32) NOT NULL REFERENCES social.roles(name) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO social.roles (name, description) VALUES
+    ('admin', 'Manages users and roles'),
+    ('moderator', 'Moderates content of other users')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO social.role_permissions (role, permission) VALUES
+    ('admin', 'posts:moderate'),
+    ('admin', 'users:unlock'),
+    ('admin', 'roles:manage'),
+    ('moderator', 'posts:moderate')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS social.magic_links (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_magic_links_user_id ON social.magic_links(user_id);
@@ -0,0 +1,8 @@
+ALTER TABLE social.users
+    ADD COLUMN birthday_visibility VARCHAR(16) NOT NULL DEFAULT 'private'
+        CHECK (birthday_visibility IN ('public', 'private'));
+
+-- Profiles are read far more often than the graph changes, so the counts are kept on the user
+-- instead of being counted on every read.
+ALTER TABLE social.users ADD COLUMN followers_count INTEGER NOT NULL DEFAULT 0 CHECK (followers_count >= 0);
+ALTER TABLE social.users ADD COLUMN following_count INTEGER NOT NULL DEFAULT 0 CHECK (following_count >= 0);
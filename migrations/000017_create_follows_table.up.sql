@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS social.follows (
+    follower_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+-- Followers and followings are listed newest first, one page at a time.
+CREATE INDEX idx_follows_followee ON social.follows(followee_id, created_at DESC, follower_id DESC);
+CREATE INDEX idx_follows_follower ON social.follows(follower_id, created_at DESC, followee_id DESC);
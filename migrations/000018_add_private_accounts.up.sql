@@ -0,0 +1,12 @@
+ALTER TABLE social.users ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Follows of private accounts wait here until the account owner accepts them.
+CREATE TABLE IF NOT EXISTS social.follow_requests (
+    requester_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    target_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (requester_id, target_id),
+    CHECK (requester_id <> target_id)
+);
+
+CREATE INDEX idx_follow_requests_target ON social.follow_requests(target_id, created_at DESC, requester_id DESC);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS social.blocks (
+    blocker_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+-- Blocked and muted users are listed newest first, one page at a time.
+CREATE INDEX idx_blocks_blocker ON social.blocks(blocker_id, created_at DESC, blocked_id DESC);
+
+CREATE TABLE IF NOT EXISTS social.mutes (
+    muter_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
+
+CREATE INDEX idx_mutes_muter ON social.mutes(muter_id, created_at DESC, muted_id DESC);
@@ -0,0 +1,7 @@
+-- Feeds page through posts by (created_at, id), which only works if every post has a created_at.
+UPDATE social.posts SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+ALTER TABLE social.posts ALTER COLUMN created_at SET NOT NULL;
+
+-- The newest posts of each author are read straight off this index. It also serves lookups by user_id alone.
+CREATE INDEX idx_posts_user_created ON social.posts(user_id, created_at DESC, id DESC);
+DROP INDEX IF EXISTS social.idx_posts_user_id;
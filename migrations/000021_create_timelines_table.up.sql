@@ -0,0 +1,16 @@
+-- Each user's home feed, written ahead by the fan-out worker. Rows go away with their post.
+CREATE TABLE IF NOT EXISTS social.timelines (
+    user_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES social.posts(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX idx_timelines_user_created ON social.timelines(user_id, created_at DESC, post_id DESC);
+CREATE INDEX idx_timelines_post ON social.timelines(post_id);
+
+-- Posts wait with fanned_out_at unset until the worker has written them to their followers' timelines.
+-- Posts from before the timelines existed are fanned out too.
+ALTER TABLE social.posts ADD COLUMN fanned_out_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_posts_pending_fan_out ON social.posts(created_at) WHERE fanned_out_at IS NULL;
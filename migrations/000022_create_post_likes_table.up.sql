@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS social.post_likes (
+    post_id UUID NOT NULL REFERENCES social.posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id)
+);
+
+-- Likers are listed newest first, one page at a time.
+CREATE INDEX idx_post_likes_post_created ON social.post_likes(post_id, created_at DESC, user_id DESC);
+CREATE INDEX idx_post_likes_user ON social.post_likes(user_id);
+
+ALTER TABLE social.posts ADD COLUMN like_count INT NOT NULL DEFAULT 0;
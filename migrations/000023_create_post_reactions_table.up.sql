@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS social.post_reactions (
+    post_id UUID NOT NULL REFERENCES social.posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES social.users(id) ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id, emoji)
+);
+
+CREATE INDEX idx_post_reactions_user ON social.post_reactions(user_id, post_id);
+
+-- Counts per post and emoji, kept in step with post_reactions so that posts do not count their reactions on every read.
+CREATE TABLE IF NOT EXISTS social.post_reaction_counts (
+    post_id UUID NOT NULL REFERENCES social.posts(id) ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (post_id, emoji)
+);
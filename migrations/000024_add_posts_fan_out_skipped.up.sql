@@ -0,0 +1,12 @@
+-- Posts by authors with too many followers are not written to timelines but read when a feed loads.
+-- That is recorded on the post, so that the post stays in feeds after the author falls below the threshold.
+ALTER TABLE social.posts ADD COLUMN fan_out_skipped BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Posts already fanned out without reaching any timeline were either skipped or had no followers to reach.
+-- Reading either kind when a feed loads is correct.
+UPDATE social.posts p
+SET fan_out_skipped = TRUE
+WHERE p.fanned_out_at IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM social.timelines t WHERE t.post_id = p.id);
+
+CREATE INDEX idx_posts_fan_out_skipped ON social.posts(user_id, created_at DESC, id DESC) WHERE fan_out_skipped;
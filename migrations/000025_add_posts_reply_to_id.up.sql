@@ -0,0 +1,7 @@
+-- A reply points at the post it answers. There is no foreign key, so that replies stay replies
+-- after the post they answered is deleted.
+ALTER TABLE social.posts ADD COLUMN reply_to_id UUID;
+
+-- Lists of a user's posts leave replies out unless asked for them.
+CREATE INDEX idx_posts_user_created_top_level ON social.posts(user_id, created_at DESC, id DESC)
+    WHERE reply_to_id IS NULL;
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type item[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTL is an in-memory key/value cache where every entry carries its own expiry.
+// It is safe for concurrent use. Expired entries are swept once the cache grows past its capacity.
+type TTL[K comparable, V any] struct {
+	mu       sync.RWMutex
+	items    map[K]item[V]
+	capacity int
+	now      func() time.Time
+}
+
+func NewTTL[K comparable, V any](capacity int) *TTL[K, V] {
+	return &TTL[K, V]{
+		items:    make(map[K]item[V]),
+		capacity: capacity,
+		now:      time.Now,
+	}
+}
+
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	it, ok := c.items[key]
+	if !ok || !c.now().Before(it.expiresAt) {
+		var zero V
+		return zero, false
+	}
+
+	return it.value, true
+}
+
+func (c *TTL[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; !ok && len(c.items) >= c.capacity {
+		c.evict()
+	}
+
+	c.items[key] = item[V]{
+		value:     value,
+		expiresAt: c.now().Add(ttl),
+	}
+}
+
+func (c *TTL[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+}
+
+func (c *TTL[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.items)
+}
+
+// evict drops expired entries and, if the cache is still full, arbitrary ones
+// until there is room for a new entry. Callers must hold the write lock.
+func (c *TTL[K, V]) evict() {
+	now := c.now()
+	for k, it := range c.items {
+		if !now.Before(it.expiresAt) {
+			delete(c.items, k)
+		}
+	}
+
+	for k := range c.items {
+		if len(c.items) < c.capacity {
+			break
+		}
+		delete(c.items, k)
+	}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTL(t *testing.T) {
+	now := time.Now()
+	c := NewTTL[string, int](2)
+	c.now = func() time.Time { return now }
+
+	t.Run("Get after Set", func(t *testing.T) {
+		c.Set("a", 1, time.Minute)
+
+		v, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		_, ok := c.Get("missing")
+		assert.False(t, ok)
+	})
+
+	t.Run("Expired entry", func(t *testing.T) {
+		c.Set("b", 2, time.Second)
+		now = now.Add(2 * time.Second)
+
+		_, ok := c.Get("b")
+		assert.False(t, ok)
+
+		v, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("Eviction keeps capacity", func(t *testing.T) {
+		c.Set("c", 3, time.Minute)
+		c.Set("d", 4, time.Minute)
+
+		assert.LessOrEqual(t, c.Len(), 2)
+		v, ok := c.Get("d")
+		assert.True(t, ok)
+		assert.Equal(t, 4, v)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c.Delete("d")
+
+		_, ok := c.Get("d")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,94 @@
+// Package hasher hashes passwords for storage. Hashes are self-describing, so a PasswordHasher can tell
+// which algorithm and parameters produced one and whether it should be replaced with a fresh hash.
+package hasher
+
+import (
+	"errors"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var (
+	ErrMismatchedPassword = errors.New("password does not match hash")
+	ErrUnknownHash        = errors.New("unknown hash format")
+)
+
+// PasswordHasher hashes passwords and checks them against stored hashes.
+type PasswordHasher interface {
+	// Hash returns an encoded hash of password that carries its own salt and parameters.
+	Hash(password string) (string, error)
+	// Verify returns nil if password matches hash, ErrMismatchedPassword if it does not
+	// and ErrUnknownHash if hash was not produced by this hasher.
+	Verify(hash, password string) error
+	// Recognizes reports whether hash was produced by this hasher's algorithm.
+	Recognizes(hash string) bool
+	// NeedsRehash reports whether hash should be replaced by Hash, because it was made
+	// with another algorithm or weaker parameters than the hasher uses now.
+	NeedsRehash(hash string) bool
+}
+
+// Chain hashes with Current and still verifies hashes made by any of Legacy,
+// so stored hashes can be upgraded as users sign in.
+type Chain struct {
+	Current PasswordHasher
+	Legacy  []PasswordHasher
+}
+
+// New returns a hasher by algorithm name. Bcrypt hashes are verified whichever algorithm is chosen.
+func New(algorithm string, argon Argon2idParams, bcryptCost int) (*Chain, error) {
+	switch algorithm {
+	case Argon2id, "":
+		current, err := NewArgon2id(argon)
+		if err != nil {
+			return nil, err
+		}
+		return &Chain{Current: current, Legacy: []PasswordHasher{NewBcrypt(bcryptCost)}}, nil
+	case Bcrypt:
+		current := NewBcrypt(bcryptCost)
+		legacy, err := NewArgon2id(argon)
+		if err != nil {
+			return nil, err
+		}
+		return &Chain{Current: current, Legacy: []PasswordHasher{legacy}}, nil
+	default:
+		return nil, errors.New("unsupported password hashing algorithm: " + algorithm)
+	}
+}
+
+func (c *Chain) Hash(password string) (string, error) {
+	return c.Current.Hash(password)
+}
+
+func (c *Chain) Verify(hash, password string) error {
+	h := c.find(hash)
+	if h == nil {
+		return ErrUnknownHash
+	}
+	return h.Verify(hash, password)
+}
+
+func (c *Chain) Recognizes(hash string) bool {
+	return c.find(hash) != nil
+}
+
+func (c *Chain) NeedsRehash(hash string) bool {
+	if !c.Current.Recognizes(hash) {
+		return true
+	}
+	return c.Current.NeedsRehash(hash)
+}
+
+func (c *Chain) find(hash string) PasswordHasher {
+	if c.Current.Recognizes(hash) {
+		return c.Current
+	}
+	for _, h := range c.Legacy {
+		if h.Recognizes(hash) {
+			return h
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package hasher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testParams keep the tests fast; they are far too weak for real use.
+var testParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestArgon2id(t *testing.T) {
+	h, err := NewArgon2id(testParams)
+	require.NoError(t, err)
+
+	hash, err := h.Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+
+	other, err := h.Hash("password123")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salts should differ")
+
+	assert.NoError(t, h.Verify(hash, "password123"))
+	assert.ErrorIs(t, h.Verify(hash, "password124"), ErrMismatchedPassword)
+	assert.False(t, h.NeedsRehash(hash))
+
+	stronger, err := NewArgon2id(Argon2idParams{Memory: 128, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	assert.True(t, stronger.NeedsRehash(hash))
+	// The parameters come from the hash, so a hasher with other parameters still verifies it.
+	assert.NoError(t, stronger.Verify(hash, "password123"))
+}
+
+func TestArgon2id_Malformed(t *testing.T) {
+	h, err := NewArgon2id(testParams)
+	require.NoError(t, err)
+
+	for _, hash := range []string{
+		"",
+		"$argon2id$",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=19$m=64,t=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$!!!",
+	} {
+		assert.ErrorIs(t, h.Verify(hash, "password123"), ErrUnknownHash, hash)
+	}
+
+	assert.Error(t, h.Verify("$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", "password123"))
+}
+
+func TestBcrypt(t *testing.T) {
+	h := NewBcrypt(bcrypt.MinCost)
+
+	hash, err := h.Hash("password123")
+	require.NoError(t, err)
+
+	assert.True(t, h.Recognizes(hash))
+	assert.NoError(t, h.Verify(hash, "password123"))
+	assert.ErrorIs(t, h.Verify(hash, "password124"), ErrMismatchedPassword)
+	assert.False(t, h.NeedsRehash(hash))
+	assert.True(t, NewBcrypt(bcrypt.MinCost+1).NeedsRehash(hash))
+}
+
+func TestChain(t *testing.T) {
+	current, err := NewArgon2id(testParams)
+	require.NoError(t, err)
+	chain := &Chain{Current: current, Legacy: []PasswordHasher{NewBcrypt(bcrypt.MinCost)}}
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	assert.NoError(t, chain.Verify(string(legacy), "password123"))
+	assert.ErrorIs(t, chain.Verify(string(legacy), "password124"), ErrMismatchedPassword)
+	assert.True(t, chain.NeedsRehash(string(legacy)))
+
+	hash, err := chain.Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, current.Recognizes(hash))
+	assert.NoError(t, chain.Verify(hash, "password123"))
+	assert.False(t, chain.NeedsRehash(hash))
+
+	// Accounts created through an external provider have no password.
+	assert.ErrorIs(t, chain.Verify("", "password123"), ErrUnknownHash)
+}
+
+func TestNew(t *testing.T) {
+	chain, err := New(Bcrypt, testParams, bcrypt.MinCost)
+	require.NoError(t, err)
+
+	hash, err := chain.Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$2a$"))
+
+	_, err = New("md5", testParams, bcrypt.MinCost)
+	assert.Error(t, err)
+}
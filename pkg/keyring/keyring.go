@@ -0,0 +1,217 @@
+// Package keyring holds the keys used to sign and verify tokens. Exactly one key signs;
+// every key in the ring verifies, so tokens signed before a rotation stay valid.
+package keyring
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	privateSuffix = ".pem"
+	publicSuffix  = ".pub.pem"
+	activeFile    = "active"
+)
+
+// Key is one entry of the ring. Private is nil for keys that only verify.
+// Algorithm is the JWS algorithm implied by the key type: RS256, ES256 or EdDSA.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+// Ring is safe for concurrent use. Reload swaps the keys in place.
+type Ring struct {
+	mu        sync.RWMutex
+	keys      map[string]*Key
+	active    *Key
+	dir       string
+	algorithm string
+}
+
+// Load reads a key directory. Every <kid>.pem holds a private key and every <kid>.pub.pem
+// a public key that is still trusted but no longer signs. The signing key is the one named in
+// the file "active", or else the private key for algorithm whose kid sorts last.
+// Keys of other algorithms still verify, which allows moving from one algorithm to another.
+//
+// To rotate, add a new private key and reload. Once every token signed with the old key has expired,
+// replace the old private key with its public key, or remove it.
+func Load(dir, algorithm string) (*Ring, error) {
+	if err := checkAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+
+	r := &Ring{dir: dir, algorithm: algorithm}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FromFiles builds a ring holding a single key pair. Its kid is the RFC 7638 thumbprint of the public key.
+func FromFiles(privatePath, publicPath, algorithm string) (*Ring, error) {
+	if err := checkAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+
+	priv, err := readKeyFile(privatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := readKeyFile(publicPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if priv.Private == nil {
+		return nil, fmt.Errorf("%s does not hold a private key", filepath.Base(privatePath))
+	}
+
+	if !samePublicKey(priv.Public, pub.Public) {
+		return nil, errors.New("public key does not match private key")
+	}
+
+	if priv.Algorithm != algorithm {
+		return nil, fmt.Errorf("key is for %s, not %s", priv.Algorithm, algorithm)
+	}
+
+	priv.ID = Thumbprint(priv.Public)
+
+	return &Ring{keys: map[string]*Key{priv.ID: priv}, active: priv, algorithm: algorithm}, nil
+}
+
+// Reload reads the key directory again. On error the ring keeps its current keys.
+// Rings built with FromFiles have nothing to reload.
+func (r *Ring) Reload() error {
+	if r.dir == "" {
+		return nil
+	}
+
+	keys, err := loadDir(r.dir)
+	if err != nil {
+		return err
+	}
+
+	active, err := pickActive(r.dir, keys, r.algorithm)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.active = active
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Active returns the signing key.
+func (r *Ring) Active() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.active
+}
+
+// Get returns the key with the given kid.
+func (r *Ring) Get(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[kid]
+	return key, ok
+}
+
+// Keys returns every key of the ring, ordered by kid.
+func (r *Ring) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys
+}
+
+func loadDir(dir string) (map[string]*Key, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key directory: %w", err)
+	}
+
+	keys := make(map[string]*Key)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, privateSuffix) {
+			continue
+		}
+
+		key, err := readKeyFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(name, publicSuffix) {
+			key.ID = strings.TrimSuffix(name, publicSuffix)
+		} else {
+			key.ID = strings.TrimSuffix(name, privateSuffix)
+		}
+
+		if existing, ok := keys[key.ID]; ok {
+			// A private key and its public key may sit side by side.
+			if !samePublicKey(existing.Public, key.Public) {
+				return nil, fmt.Errorf("key %q: public key does not match private key", key.ID)
+			}
+			if existing.Private == nil {
+				existing.Private = key.Private
+			}
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	return keys, nil
+}
+
+func pickActive(dir string, keys map[string]*Key, algorithm string) (*Key, error) {
+	name, err := os.ReadFile(filepath.Join(dir, activeFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read active key: %w", err)
+	}
+
+	if kid := strings.TrimSpace(string(name)); kid != "" {
+		key, ok := keys[kid]
+		if !ok || key.Private == nil {
+			return nil, fmt.Errorf("active key %q has no private key", kid)
+		}
+		if key.Algorithm != algorithm {
+			return nil, fmt.Errorf("active key %q is for %s, not %s", kid, key.Algorithm, algorithm)
+		}
+		return key, nil
+	}
+
+	var active *Key
+	for _, key := range keys {
+		if key.Private != nil && key.Algorithm == algorithm && (active == nil || key.ID > active.ID) {
+			active = key
+		}
+	}
+
+	if active == nil {
+		return nil, fmt.Errorf("no %s private key in %s", algorithm, dir)
+	}
+
+	return active, nil
+}
@@ -0,0 +1,314 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateKey(t *testing.T, algorithm string) crypto.Signer {
+	t.Helper()
+
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch algorithm {
+	case RS256:
+		key, err = rsa.GenerateKey(rand.Reader, 1024)
+	case ES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	require.NoError(t, err)
+
+	return key
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func writeKey(t *testing.T, dir, kid, algorithm string, publicOnly bool) crypto.Signer {
+	t.Helper()
+
+	key := generateKey(t, algorithm)
+
+	if publicOnly {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		require.NoError(t, err)
+		writePEM(t, filepath.Join(dir, kid+publicSuffix), "PUBLIC KEY", der)
+		return key
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, kid+privateSuffix), "PRIVATE KEY", der)
+
+	return key
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2024-01", RS256, false)
+	newest := writeKey(t, dir, "2024-06", RS256, false)
+	retired := writeKey(t, dir, "2023-01", RS256, true)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0o600))
+
+	ring, err := Load(dir, RS256)
+	require.NoError(t, err)
+
+	t.Run("Newest private key signs", func(t *testing.T) {
+		active := ring.Active()
+		assert.Equal(t, "2024-06", active.ID)
+		assert.True(t, samePublicKey(newest.Public(), active.Public))
+		assert.NotNil(t, active.Private)
+	})
+
+	t.Run("Public only key verifies", func(t *testing.T) {
+		key, ok := ring.Get("2023-01")
+		require.True(t, ok)
+		assert.Nil(t, key.Private)
+		assert.True(t, samePublicKey(retired.Public(), key.Public))
+	})
+
+	t.Run("Unknown kid", func(t *testing.T) {
+		_, ok := ring.Get("missing")
+		assert.False(t, ok)
+	})
+
+	t.Run("Keys are ordered", func(t *testing.T) {
+		keys := ring.Keys()
+		require.Len(t, keys, 3)
+		assert.Equal(t, "2023-01", keys[0].ID)
+		assert.Equal(t, "2024-06", keys[2].ID)
+	})
+}
+
+func TestLoad_Algorithms(t *testing.T) {
+	for _, algorithm := range []string{RS256, ES256, EdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKey(t, dir, "k1", algorithm, false)
+
+			ring, err := Load(dir, algorithm)
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, ring.Active().Algorithm)
+		})
+	}
+
+	t.Run("Unsupported algorithm", func(t *testing.T) {
+		_, err := Load(t.TempDir(), "HS256")
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad_MixedAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2024-01", RS256, false)
+	writeKey(t, dir, "2024-06", EdDSA, false)
+	writeKey(t, dir, "2024-09", RS256, false)
+
+	// The newest key for the configured algorithm signs, and every key verifies.
+	ring, err := Load(dir, EdDSA)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-06", ring.Active().ID)
+	assert.Len(t, ring.Keys(), 3)
+
+	_, err = Load(dir, ES256)
+	assert.Error(t, err)
+}
+
+func TestLoad_PEMTypes(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey := generateKey(t, RS256).(*rsa.PrivateKey)
+	writePEM(t, filepath.Join(dir, "pkcs1.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	writePEM(t, filepath.Join(dir, "pkcs1.pub.pem"), "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+
+	ecKey := generateKey(t, ES256).(*ecdsa.PrivateKey)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "sec1.pem"), "EC PRIVATE KEY", der)
+
+	ring, err := Load(dir, RS256)
+	require.NoError(t, err)
+
+	key, ok := ring.Get("pkcs1")
+	require.True(t, ok)
+	assert.Equal(t, RS256, key.Algorithm)
+	assert.NotNil(t, key.Private)
+
+	key, ok = ring.Get("sec1")
+	require.True(t, ok)
+	assert.Equal(t, ES256, key.Algorithm)
+
+	t.Run("Unsupported curve", func(t *testing.T) {
+		dir := t.TempDir()
+		p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(p384)
+		require.NoError(t, err)
+		writePEM(t, filepath.Join(dir, "p384.pem"), "PRIVATE KEY", der)
+
+		_, err = Load(dir, ES256)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad_ActiveFile(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", RS256, false)
+	writeKey(t, dir, "b", RS256, false)
+	writeKey(t, dir, "c", RS256, true)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, activeFile), []byte("a\n"), 0o600))
+
+	ring, err := Load(dir, RS256)
+	require.NoError(t, err)
+	assert.Equal(t, "a", ring.Active().ID)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, activeFile), []byte("c"), 0o600))
+	_, err = Load(dir, RS256)
+	assert.Error(t, err)
+}
+
+func TestLoad_NoPrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", RS256, true)
+
+	_, err := Load(dir, RS256)
+	assert.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2024-01", RS256, false)
+
+	ring, err := Load(dir, RS256)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01", ring.Active().ID)
+
+	writeKey(t, dir, "2024-02", RS256, false)
+	require.NoError(t, ring.Reload())
+	assert.Equal(t, "2024-02", ring.Active().ID)
+
+	_, ok := ring.Get("2024-01")
+	assert.True(t, ok, "the previous key keeps verifying")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("garbage"), 0o600))
+	assert.Error(t, ring.Reload())
+	assert.Equal(t, "2024-02", ring.Active().ID, "a failed reload keeps the current keys")
+}
+
+func TestFromFiles(t *testing.T) {
+	ring, err := FromFiles("../../certs/local/private.pem", "../../certs/local/public.pem", RS256)
+	require.NoError(t, err)
+
+	active := ring.Active()
+	require.NotNil(t, active.Private)
+	assert.Equal(t, Thumbprint(active.Public), active.ID)
+	assert.NoError(t, ring.Reload())
+
+	_, err = FromFiles("../../certs/local/private.pem", "../../certs/local/public.pem", EdDSA)
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	writeKey(t, dir, "other", RS256, true)
+	_, err = FromFiles("../../certs/local/private.pem", filepath.Join(dir, "other"+publicSuffix), RS256)
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeKey(t, dir, "k1", RS256, false).(*rsa.PrivateKey)
+	ecKey := writeKey(t, dir, "k2", ES256, false).(*ecdsa.PrivateKey)
+	edKey := writeKey(t, dir, "k3", EdDSA, true).(ed25519.PrivateKey)
+
+	ring, err := Load(dir, RS256)
+	require.NoError(t, err)
+
+	set := ring.JWKS()
+	require.Len(t, set.Keys, 3)
+
+	t.Run("RSA", func(t *testing.T) {
+		jwk := set.Keys[0]
+		assert.Equal(t, "RSA", jwk.Kty)
+		assert.Equal(t, RS256, jwk.Alg)
+		assert.Equal(t, "sig", jwk.Use)
+		assert.Equal(t, "k1", jwk.Kid)
+		assert.Equal(t, "AQAB", jwk.E)
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		require.NoError(t, err)
+		assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(rsaKey.N))
+	})
+
+	t.Run("EC", func(t *testing.T) {
+		jwk := set.Keys[1]
+		assert.Equal(t, "EC", jwk.Kty)
+		assert.Equal(t, ES256, jwk.Alg)
+		assert.Equal(t, "P-256", jwk.Crv)
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		require.NoError(t, err)
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		require.NoError(t, err)
+
+		point, err := ecKey.PublicKey.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, point, append(append([]byte{4}, x...), y...))
+	})
+
+	t.Run("OKP", func(t *testing.T) {
+		jwk := set.Keys[2]
+		assert.Equal(t, "OKP", jwk.Kty)
+		assert.Equal(t, EdDSA, jwk.Alg)
+		assert.Equal(t, "Ed25519", jwk.Crv)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)), jwk.X)
+	})
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 8037, appendix A.3.
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	require.NoError(t, err)
+
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", Thumbprint(ed25519.PublicKey(x)))
+}
+
+func TestJWK_PublicKey(t *testing.T) {
+	for _, algorithm := range []string{RS256, ES256, EdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			signer := generateKey(t, algorithm)
+			key := &Key{ID: "k", Algorithm: algorithm, Private: signer, Public: signer.Public()}
+
+			pub, err := key.JWK().PublicKey()
+			require.NoError(t, err)
+			assert.True(t, samePublicKey(signer.Public(), pub))
+		})
+	}
+
+	_, err := JWK{Kty: "EC", Crv: "P-384"}.PublicKey()
+	assert.Error(t, err)
+
+	_, err = JWK{Kty: "oct"}.PublicKey()
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+func checkAlgorithm(algorithm string) error {
+	switch algorithm {
+	case RS256, ES256, EdDSA:
+		return nil
+	default:
+		return fmt.Errorf("unsupported signing algorithm: %q", algorithm)
+	}
+}
+
+// readKeyFile loads a PEM encoded private or public key, detecting its type from the PEM block.
+func readKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+
+	key, err := parsePEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse key %s: %w", filepath.Base(path), err)
+	}
+
+	return key, nil
+}
+
+func parsePEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey(priv)
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey(priv)
+	case "EC PRIVATE KEY":
+		priv, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey(priv)
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return publicKey(pub)
+	case "RSA PUBLIC KEY":
+		pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return publicKey(pub)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func privateKey(priv any) (*Key, error) {
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+
+	key, err := publicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.Private = signer
+
+	return key, nil
+}
+
+func publicKey(pub any) (*Key, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return &Key{Algorithm: RS256, Public: pub}, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s, only P-256 is supported", pub.Curve.Params().Name)
+		}
+		return &Key{Algorithm: ES256, Public: pub}, nil
+	case ed25519.PublicKey:
+		return &Key{Algorithm: EdDSA, Public: pub}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func samePublicKey(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
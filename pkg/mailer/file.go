@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// File writes every message as an .eml file into a directory. It is meant for local development.
+type File struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("could not create mail directory: %w", err)
+	}
+
+	return &File{
+		dir:  dir,
+		from: from,
+	}, nil
+}
+
+func (f *File) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s_%s.eml", now.UTC().Format("20060102T150405"), uuid.NewString())
+
+	return os.WriteFile(filepath.Join(f.dir, name), format(f.from, msg, now), 0o600)
+}
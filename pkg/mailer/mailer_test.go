@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+
+	_, ok := m.Last("john@example.com")
+	assert.False(t, ok)
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "john@example.com", Subject: "first"}))
+	require.NoError(t, m.Send(context.Background(), Message{To: "jane@example.com", Subject: "other"}))
+	require.NoError(t, m.Send(context.Background(), Message{To: "john@example.com", Subject: "second"}))
+
+	msg, ok := m.Last("john@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "second", msg.Subject)
+	assert.Len(t, m.Sent(), 3)
+}
+
+func TestFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	f, err := NewFile(dir, "noreply@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, f.Send(context.Background(), Message{
+		To:      "john@example.com",
+		Subject: "Hello",
+		Body:    "line one\nline two",
+	}))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: john@example.com\r\n")
+	assert.Contains(t, string(data), "line one\r\nline two")
+}
+
+func TestFormat_StripsHeaderInjection(t *testing.T) {
+	raw := string(format("noreply@example.com", Message{
+		To:      "john@example.com\r\nBcc: evil@example.com",
+		Subject: "Hi\nBcc: evil@example.com",
+	}, time.Now()))
+
+	headers := raw[:strings.Index(raw, "\r\n\r\n")]
+	for _, line := range strings.Split(headers, "\r\n") {
+		assert.False(t, strings.HasPrefix(line, "Bcc:"), line)
+	}
+}
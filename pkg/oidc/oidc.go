@@ -0,0 +1,307 @@
+// Package oidc is a minimal OpenID Connect relying party: discovery, the authorization
+// code flow with PKCE and verification of ID tokens against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/defskela/SocialNetwork/pkg/keyring"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// keysRefreshInterval limits how often an unknown kid makes the provider's keys be fetched again.
+	keysRefreshInterval = time.Minute
+	maxResponseSize     = 1 << 20
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Config describes a client registered with an OpenID provider.
+type Config struct {
+	// Issuer is the issuer URL of the provider. The discovery document is read from below it.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested in addition to openid. Defaults to email and profile.
+	Scopes []string
+	// Leeway is the clock skew tolerated when checking the ID token.
+	Leeway time.Duration
+}
+
+// Metadata is the part of the provider's discovery document the flow needs.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Token is the response of the token endpoint.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Claims are the claims of an ID token this package understands.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified,omitempty"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// Provider talks to one OpenID provider. The discovery document and the keys are fetched
+// on first use and cached, so a provider that is down does not stop the service from starting.
+// It is safe for concurrent use.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          map[string]keyring.JWK
+	keysFetchedAt time.Time
+}
+
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+
+	return &Provider{cfg: cfg, client: client}
+}
+
+// AuthCodeURL returns the URL of the provider's consent page. state and nonce are echoed back
+// in the redirect and in the ID token, and verifier has to be presented again to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var token Token
+	if err := p.do(req, &token); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return &token, nil
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return p.verificationKey(ctx, token)
+		},
+		jwt.WithValidMethods([]string{keyring.RS256, keyring.ES256, keyring.EdDSA}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithLeeway(p.cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return &claims, nil
+}
+
+func (p *Provider) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	jwk, err := p.key(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if jwk.Alg != "" && jwk.Alg != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q is not for %s", kid, token.Method.Alg())
+	}
+
+	return jwk.PublicKey()
+}
+
+// key looks a signing key up by kid. An unknown kid usually means the provider rotated its keys,
+// so they are fetched again, though not more than once per keysRefreshInterval.
+func (p *Provider) key(ctx context.Context, kid string) (keyring.JWK, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if jwk, ok := p.lookupKey(kid); ok {
+		return jwk, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < keysRefreshInterval {
+		return keyring.JWK{}, fmt.Errorf("unknown key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.metadata.JWKSURI, http.NoBody)
+	if err != nil {
+		return keyring.JWK{}, err
+	}
+
+	var set keyring.JWKS
+	if err := p.do(req, &set); err != nil {
+		return keyring.JWK{}, fmt.Errorf("failed to fetch keys: %w", err)
+	}
+
+	p.keys = make(map[string]keyring.JWK, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use == "" || jwk.Use == "sig" {
+			p.keys[jwk.Kid] = jwk
+		}
+	}
+	p.keysFetchedAt = time.Now()
+
+	if jwk, ok := p.lookupKey(kid); ok {
+		return jwk, nil
+	}
+
+	return keyring.JWK{}, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookupKey finds a key by kid. A token without a kid is accepted only when the provider has a single key.
+func (p *Provider) lookupKey(kid string) (keyring.JWK, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, jwk := range p.keys {
+			return jwk, true
+		}
+	}
+
+	jwk, ok := p.keys[kid]
+	return jwk, ok
+}
+
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Issuer+discoveryPath, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata Metadata
+	if err := p.do(req, &metadata); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+
+	// The issuer has to match exactly, or a provider could issue tokens in the name of another.
+	if metadata.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery returned issuer %q, expected %q", metadata.Issuer, p.cfg.Issuer)
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	p.metadata = &metadata
+
+	return p.metadata, nil
+}
+
+func (p *Provider) do(req *http.Request, out any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, out)
+}
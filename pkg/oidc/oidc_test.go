@@ -0,0 +1,167 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defskela/SocialNetwork/pkg/oidc"
+	"github.com/defskela/SocialNetwork/pkg/oidc/oidctest"
+)
+
+const redirectURL = "http://app.example.com/callback"
+
+// authorize follows the consent page and returns the code and state from the redirect.
+func authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, authURL, http.NoBody)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestProvider_Flow(t *testing.T) {
+	srv := oidctest.NewServer("client", "secret")
+	defer srv.Close()
+	srv.SetUser(oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+
+	provider := oidc.NewProvider(srv.Config(redirectURL), srv.Client())
+	ctx := context.Background()
+
+	verifier, err := oidc.NewVerifier()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+
+	code, state := authorize(t, authURL)
+	assert.Equal(t, "state-1", state)
+
+	token, err := provider.Exchange(ctx, code, verifier)
+	require.NoError(t, err)
+
+	claims, err := provider.Verify(ctx, token.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.Equal(t, "alice@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	_, err = provider.Verify(ctx, token.IDToken, "other-nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+
+	// The code was used up.
+	_, err = provider.Exchange(ctx, code, verifier)
+	assert.Error(t, err)
+}
+
+func TestProvider_WrongVerifier(t *testing.T) {
+	srv := oidctest.NewServer("client", "secret")
+	defer srv.Close()
+	srv.SetUser(oidctest.User{Subject: "alice"})
+
+	provider := oidc.NewProvider(srv.Config(redirectURL), srv.Client())
+	ctx := context.Background()
+
+	verifier, err := oidc.NewVerifier()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+	require.NoError(t, err)
+
+	code, _ := authorize(t, authURL)
+
+	other, err := oidc.NewVerifier()
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, code, other)
+	assert.ErrorContains(t, err, "invalid_grant")
+}
+
+func TestProvider_Verify(t *testing.T) {
+	srv := oidctest.NewServer("client", "secret")
+	defer srv.Close()
+
+	provider := oidc.NewProvider(srv.Config(redirectURL), srv.Client())
+	ctx := context.Background()
+	now := time.Now()
+
+	valid := func() *oidc.Claims {
+		return &oidc.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    srv.URL,
+				Subject:   "alice",
+				Audience:  jwt.ClaimStrings{"client"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+			Nonce: "nonce",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*oidc.Claims)
+		valid  bool
+	}{
+		{"Valid", func(*oidc.Claims) {}, true},
+		{"Other audience", func(c *oidc.Claims) { c.Audience = jwt.ClaimStrings{"other"} }, false},
+		{"Other issuer", func(c *oidc.Claims) { c.Issuer = "https://evil.example.com" }, false},
+		{"Expired", func(c *oidc.Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }, false},
+		{"No expiry", func(c *oidc.Claims) { c.ExpiresAt = nil }, false},
+		{"No subject", func(c *oidc.Claims) { c.Subject = "" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+
+			raw, err := srv.IDToken(claims)
+			require.NoError(t, err)
+
+			_, err = provider.Verify(ctx, raw, "nonce")
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+			}
+		})
+	}
+}
+
+func TestProvider_IssuerMismatch(t *testing.T) {
+	srv := oidctest.NewServer("client", "secret")
+	defer srv.Close()
+
+	cfg := srv.Config(redirectURL)
+	cfg.Issuer = "http://" + srv.Listener.Addr().String() + "/tenant"
+	provider := oidc.NewProvider(cfg, srv.Client())
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.Error(t, err)
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636, appendix B.
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		oidc.Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
@@ -0,0 +1,81 @@
+// Package ratelimit limits how often a key, such as a client address, may do something.
+// Each key has a token bucket that holds up to Burst tokens and refills at Rate tokens per second.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// Limiter keeps one bucket per key in memory, so every replica limits on its own. It is safe for concurrent use.
+type Limiter struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	capacity int
+	buckets  map[string]*bucket
+	now      func() time.Time
+}
+
+// New returns a limiter that allows burst requests at once and rate requests per second after that.
+// At most capacity keys are tracked; when there are more, the keys that have been idle long enough
+// to have a full bucket again are forgotten, which does not change what they are allowed.
+func New(rate float64, burst, capacity int) *Limiter {
+	return &Limiter{
+		rate:     rate,
+		burst:    float64(burst),
+		capacity: capacity,
+		buckets:  make(map[string]*bucket),
+		now:      time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key. If the bucket is empty, it reports how long until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.capacity {
+			l.evict(now)
+		}
+		b = &bucket{tokens: l.burst, updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updatedAt).Seconds()*l.rate)
+	b.updatedAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// evict forgets full buckets and, if that does not make room, arbitrary ones. Callers must hold the lock.
+func (l *Limiter) evict(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updatedAt).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+
+	for key := range l.buckets {
+		if len(l.buckets) < l.capacity {
+			break
+		}
+		delete(l.buckets, key)
+	}
+}
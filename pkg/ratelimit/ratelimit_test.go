@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := New(2, 3, 10)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok, "request %d is within the burst", i)
+	}
+
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// Other keys have their own bucket.
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+
+	ok, _ = l.Allow("a")
+	assert.False(t, ok)
+
+	// The bucket never holds more than the burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ = l.Allow("a")
+		assert.True(t, ok)
+	}
+	ok, _ = l.Allow("a")
+	assert.False(t, ok)
+}
+
+func TestLimiter_Evict(t *testing.T) {
+	now := time.Now()
+	l := New(1, 1, 2)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+
+	// a and b are empty, so tracking c forgets one of them.
+	l.Allow("c")
+	assert.Len(t, l.buckets, 2)
+
+	// Once refilled, buckets are dropped before any that are still limited.
+	now = now.Add(time.Second)
+	l.Allow("d")
+	ok, _ := l.Allow("d")
+	assert.False(t, ok)
+	assert.LessOrEqual(t, len(l.buckets), 2)
+	assert.Contains(t, l.buckets, "d")
+}
@@ -0,0 +1,111 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerate_RFC6238Vectors(t *testing.T) {
+	key, err := decodeSecret(rfcSecret)
+	require.NoError(t, err)
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		assert.Equal(t, tt.code, generate(key, uint64(step), 8), "time %d", tt.unix)
+	}
+}
+
+func TestCode(t *testing.T) {
+	code, err := Code(rfcSecret, time.Unix(59, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	_, err = Code("not base32!", time.Unix(59, 0))
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	t.Run("Current step", func(t *testing.T) {
+		step, ok := Validate(secret, code, now, 1)
+		assert.True(t, ok)
+		assert.Equal(t, Step(now), step)
+	})
+
+	t.Run("Within skew", func(t *testing.T) {
+		step, ok := Validate(secret, code, now.Add(Period), 1)
+		assert.True(t, ok)
+		assert.Equal(t, Step(now), step)
+	})
+
+	t.Run("Outside skew", func(t *testing.T) {
+		_, ok := Validate(secret, code, now.Add(2*Period), 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		_, ok := Validate(secret, wrong, now, 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("Wrong length", func(t *testing.T) {
+		_, ok := Validate(secret, code+"0", now, 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("Lowercase secret", func(t *testing.T) {
+		lower := []byte(secret)
+		for i, c := range lower {
+			if c >= 'A' && c <= 'Z' {
+				lower[i] = c + ('a' - 'A')
+			}
+		}
+		_, ok := Validate(string(lower), code, now, 0)
+		assert.True(t, ok)
+	})
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Social Network", "john@example.com", "JBSWY3DPEHPK3PXP")
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Social Network:john@example.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Social Network", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}